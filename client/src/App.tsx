@@ -3,7 +3,7 @@ import AuthCallback from "./AuthCallback";
 import { Link, Outlet, Route, Routes } from "react-router-dom";
 import { QueryClient, QueryClientProvider } from "@tanstack/react-query";
 import { Toaster } from "react-hot-toast";
-import { startLogin, useUser } from "./api";
+import { logout, startLogin, useUser } from "./api";
 import FullscreenText from "./components/FullscreenText";
 import History from "./History";
 import Schedule from "./Schedule";
@@ -20,7 +20,7 @@ function App() {
 					<Route path="/history" element={<History />} />
 					<Route path="/schedule" element={<Schedule />} />
 				</Route>
-				<Route path="/auth/google/callback" element={<AuthCallback />} />
+				<Route path="/auth/:provider/callback" element={<AuthCallback />} />
 			</Routes>
 		</QueryClientProvider>
 	);
@@ -42,7 +42,12 @@ function Layout() {
 					<Link to="/schedule">Schedule</Link>
 				</div>
 				{user ? (
-					<p>Hello {user.name}!</p>
+					<div className="flex flex-row gap-6 items-center">
+						<p>Hello {user.name}!</p>
+						<button onClick={logout} type="button">
+							Sign Out
+						</button>
+					</div>
 				) : (
 					<button onClick={startLogin} type="button">
 						Sign In
@@ -1,16 +1,17 @@
 import { useEffect } from "react";
-import { useNavigate } from "react-router-dom";
+import { useNavigate, useParams } from "react-router-dom";
 import { authCallback } from "./api";
 import FullscreenText from "./components/FullscreenText";
 
 function AuthCallback() {
   const navigate = useNavigate();
+  const { provider = "google" } = useParams();
 
   useEffect(() => {
-    authCallback().then(() => {
+    authCallback(provider).then(() => {
       navigate("/");
     });
-  }, [navigate]);
+  }, [navigate, provider]);
 
   return <FullscreenText text="Authenticating..." />;
 }
@@ -1,5 +1,13 @@
-import { useQuery } from "@tanstack/react-query";
-import type { User, Event, Picks, PicksWithEvent, EventInfo } from "./types";
+import { useQuery, useQueryClient } from "@tanstack/react-query";
+import { useEffect } from "react";
+import type {
+	User,
+	Event,
+	Picks,
+	PicksWithEvent,
+	EventInfo,
+	ResultUpdate,
+} from "./types";
 
 const API_URL = "http://localhost:8000/";
 
@@ -21,8 +29,13 @@ export function startLogin() {
 	window.location.assign(`${API_URL}login`);
 }
 
-export function authCallback() {
-	return callApi(`auth/google/callback${window.location.search}`);
+export async function logout() {
+	await callApi("logout", { method: "POST" });
+	window.location.assign("/");
+}
+
+export function authCallback(provider: string) {
+	return callApi(`auth/${provider}/callback${window.location.search}`);
 }
 
 export function useUser() {
@@ -42,6 +55,24 @@ export function useUser() {
 }
 
 export function useEvent(eventId: string) {
+	const queryClient = useQueryClient();
+
+	// results are pushed while the card is live, polling is only a fallback
+	useEffect(() => {
+		const source = new EventSource(`${API_URL}events/${eventId}/stream`, {
+			withCredentials: true,
+		});
+		source.addEventListener("results", (e) => {
+			const update = JSON.parse(e.data) as ResultUpdate;
+			queryClient.setQueryData([`events/${eventId}`], update.event);
+			if (update.event.fights.every((f) => f.winner)) {
+				// the server ends the stream once results are final, don't reconnect
+				source.close();
+			}
+		});
+		return () => source.close();
+	}, [eventId, queryClient]);
+
 	return useQuery<Event>({
 		queryKey: [`events/${eventId}`],
 		queryFn: () => callApi<Event>(`events/${eventId}`),
@@ -1,4 +1,14 @@
-import type { Event } from "../types";
+import type { Event, Fight } from "../types";
+
+function formatOdds(fight: Fight, fighter: string) {
+	const price = fight.odds?.[fighter];
+	if (price === undefined) {
+		return "";
+	}
+	const opponent = fight.fighters.find((f) => f !== fighter) ?? "";
+	const underdog = price > (fight.odds?.[opponent] ?? price);
+	return ` (${price > 0 ? "+" : ""}${price}${underdog ? ", underdog" : ""})`;
+}
 
 interface EventDisplayProps {
 	event: Event;
@@ -51,6 +61,7 @@ function EventDisplay({
 								type="button"
 							>
 								{fight.fighters[0] +
+									formatOdds(fight, fight.fighters[0]) +
 									(fight.winner === fight.fighters[0] ? " 🏆" : "")}
 							</button>
 							<p className="flex-1 text-center text-xl font-bold">vs</p>
@@ -66,6 +77,7 @@ function EventDisplay({
 								type="button"
 							>
 								{fight.fighters[1] +
+									formatOdds(fight, fight.fighters[1]) +
 									(fight.winner === fight.fighters[1] ? " 🏆" : "")}
 							</button>
 						</div>
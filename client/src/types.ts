@@ -6,23 +6,37 @@ export type User = {
 
 export type Event = {
 	id: string;
+	league: string;
 	name: string;
 	start_time: string;
 	fights: Fight[];
+	fetched_at?: string;
+	stale?: boolean;
 };
 
 export type Fight = {
 	fighters: string[];
 	winner?: string;
+	disputed?: boolean;
+	no_winner?: boolean;
+	unconfirmed?: boolean;
+	odds?: Record<string, number>;
+};
+
+export type ResultUpdate = {
+	event: Event;
+	changed: Fight[];
 };
 
 export type EventInfo = {
 	id: string;
+	league: string;
 	name: string;
 	date: string;
 };
 
 export type Picks = {
+	league: string;
 	winners: string[];
 	score?: number;
 };
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joho/godotenv"
+	"github.com/thebenkogan/ufc/internal/archive"
+	"github.com/thebenkogan/ufc/internal/events"
+	"github.com/thebenkogan/ufc/internal/model"
+)
+
+// Imports past events and their results into the events table.
+// Events that are already stored with every result are skipped, so an interrupted run
+// can be restarted, and events stored before all their results were in are fetched again.
+func main() {
+	ctx := context.Background()
+	if err := run(ctx, os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string) error {
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt)
+	defer cancel()
+
+	flags := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	league := flags.String("league", model.LeagueUFC, "league to import")
+	from := flags.Int("from", 1993, "first year to import")
+	to := flags.Int("to", time.Now().Year(), "last year to import")
+	interval := flags.Duration("interval", 2*time.Second, "minimum time between requests to ESPN")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if err := godotenv.Load(); err != nil {
+		return fmt.Errorf("error loading .env file: %w", err)
+	}
+
+	pgUrl := fmt.Sprintf(
+		"postgres://%s:%s@%s:%s/%s",
+		os.Getenv("POSTGRES_USER"),
+		os.Getenv("POSTGRES_PASSWORD"),
+		os.Getenv("POSTGRES_HOST"),
+		os.Getenv("POSTGRES_PORT"),
+		os.Getenv("POSTGRES_USER"),
+	)
+	pgCfg, err := pgxpool.ParseConfig(pgUrl)
+	if err != nil {
+		return fmt.Errorf("error parsing postgres URL: %w", err)
+	}
+	pool, err := pgxpool.NewWithConfig(ctx, pgCfg)
+	if err != nil {
+		return fmt.Errorf("error creating postgres pool: %w", err)
+	}
+	defer pool.Close()
+
+	eventArchive := archive.NewPostgresEventArchive(pool)
+	scraper := events.NewESPNEventScraper()
+
+	limiter := time.NewTicker(*interval)
+	defer limiter.Stop()
+	wait := func() error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-limiter.C:
+			return nil
+		}
+	}
+
+	imported, skipped, err := backfill(ctx, scraper, eventArchive, wait, *league, *from, *to)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("backfill complete", "imported", imported, "skipped", skipped)
+	return nil
+}
+
+// pastEventScraper scrapes the events held in past years
+type pastEventScraper interface {
+	ScrapePastEvents(ctx context.Context, league string, year int) ([]*model.EventInfo, error)
+	ScrapeEvent(ctx context.Context, league, id string) (*model.Event, error)
+}
+
+// Imports the events of the league held from one year to another, calling wait before each request.
+// Returns the number of events imported and the number skipped because they were already complete.
+func backfill(ctx context.Context, scraper pastEventScraper, eventArchive archive.EventArchiveRepository, wait func() error, league string, from, to int) (int, int, error) {
+	imported, skipped := 0, 0
+	for year := from; year <= to; year++ {
+		if err := wait(); err != nil {
+			return imported, skipped, err
+		}
+		infos, err := scraper.ScrapePastEvents(ctx, league, year)
+		if err != nil {
+			return imported, skipped, fmt.Errorf("error scraping %d events: %w", year, err)
+		}
+		slog.Info("found events", "league", league, "year", year, "total", len(infos))
+
+		for _, info := range infos {
+			archived, err := eventArchive.GetEvent(ctx, info.Id)
+			if err != nil {
+				return imported, skipped, fmt.Errorf("error checking event %s: %w", info.Id, err)
+			}
+			// events with a draw or no contest never look finished to the scraper, so they are fetched again each run
+			if archived != nil && archived.IsFinished() {
+				skipped++
+				continue
+			}
+
+			if err := wait(); err != nil {
+				return imported, skipped, err
+			}
+			event, err := scraper.ScrapeEvent(ctx, league, info.Id)
+			if err != nil {
+				slog.Warn("failed to scrape event", "event ID", info.Id, "error", err)
+				continue
+			}
+			if event.Id == "" {
+				event.Id = info.Id
+			}
+			if event.StartTime == "LIVE" || !event.HasStarted() {
+				slog.Info("event has not happened yet, skipping", "event ID", event.Id)
+				continue
+			}
+
+			if err := eventArchive.SaveEvent(ctx, event); err != nil {
+				return imported, skipped, fmt.Errorf("error saving event %s: %w", event.Id, err)
+			}
+			imported++
+			slog.Info("imported event", "event ID", event.Id, "name", event.Name, "fights", len(event.Fights))
+		}
+	}
+	return imported, skipped, nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thebenkogan/ufc/internal/model"
+)
+
+type testArchive struct {
+	events map[string]*model.Event
+}
+
+func (a *testArchive) GetEvent(_ context.Context, id string) (*model.Event, error) {
+	return a.events[id], nil
+}
+
+func (a *testArchive) SaveEvent(_ context.Context, event *model.Event) error {
+	a.events[event.Id] = event
+	return nil
+}
+
+type testScraper struct {
+	infos   map[int][]*model.EventInfo
+	events  map[string]*model.Event
+	scraped []string
+}
+
+func (s *testScraper) ScrapePastEvents(_ context.Context, _ string, year int) ([]*model.EventInfo, error) {
+	return s.infos[year], nil
+}
+
+func (s *testScraper) ScrapeEvent(_ context.Context, _, id string) (*model.Event, error) {
+	s.scraped = append(s.scraped, id)
+	return s.events[id], nil
+}
+
+func TestBackfill(t *testing.T) {
+	ctx := context.Background()
+	past := time.Now().Add(-24 * time.Hour).UTC().Format(time.RFC3339)
+	finished := func(id string) *model.Event {
+		return &model.Event{Id: id, StartTime: past, Fights: []model.Fight{{Fighters: []string{"A", "B"}, Winner: "A"}}}
+	}
+
+	scraper := &testScraper{
+		infos: map[int][]*model.EventInfo{
+			2023: {{Id: "1"}, {Id: "2"}},
+			2024: {{Id: "3"}, {Id: "4"}},
+		},
+		events: map[string]*model.Event{
+			"2": finished("2"),
+			"3": finished("3"),
+			"4": {Id: "4", StartTime: time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)},
+		},
+	}
+	eventArchive := &testArchive{events: map[string]*model.Event{
+		// archived with every result
+		"1": finished("1"),
+		// archived before all results were in
+		"2": {Id: "2", StartTime: past, Fights: []model.Fight{{Fighters: []string{"A", "B"}}}},
+	}}
+	waits := 0
+	wait := func() error {
+		waits++
+		return nil
+	}
+
+	imported, skipped, err := backfill(ctx, scraper, eventArchive, wait, model.LeagueUFC, 2023, 2024)
+	require.NoError(t, err)
+	assert.Equal(t, 2, imported)
+	assert.Equal(t, 1, skipped)
+	assert.Equal(t, []string{"2", "3", "4"}, scraper.scraped)
+	assert.True(t, eventArchive.events["2"].IsFinished())
+	assert.NotContains(t, eventArchive.events, "4", "upcoming events should not be archived")
+	// one request per year and per scraped event
+	assert.Equal(t, 5, waits)
+
+	// a resumed run skips everything that is complete
+	scraper.scraped = nil
+	imported, skipped, err = backfill(ctx, scraper, eventArchive, wait, model.LeagueUFC, 2023, 2024)
+	require.NoError(t, err)
+	assert.Equal(t, 0, imported)
+	assert.Equal(t, 3, skipped)
+	assert.Equal(t, []string{"4"}, scraper.scraped)
+}
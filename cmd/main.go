@@ -18,8 +18,21 @@ import (
 	"github.com/thebenkogan/ufc/internal/auth"
 	"github.com/thebenkogan/ufc/internal/cache"
 	"github.com/thebenkogan/ufc/internal/events"
+	"github.com/thebenkogan/ufc/internal/feeds"
+	"github.com/thebenkogan/ufc/internal/live"
+	"github.com/thebenkogan/ufc/internal/model"
+	"github.com/thebenkogan/ufc/internal/notify"
+	"github.com/thebenkogan/ufc/internal/odds"
 	"github.com/thebenkogan/ufc/internal/picks"
+	"github.com/thebenkogan/ufc/internal/resolutions"
 	"github.com/thebenkogan/ufc/internal/server"
+	"github.com/thebenkogan/ufc/internal/users"
+	"github.com/thebenkogan/ufc/internal/webhooks"
+)
+
+const (
+	memoryCacheSize = 1000
+	tieredL1TTL     = 30 * time.Second
 )
 
 func main() {
@@ -41,19 +54,64 @@ func run(ctx context.Context) error {
 	host, port := os.Getenv("HOST"), os.Getenv("PORT")
 	address := fmt.Sprintf("%s:%s", host, port)
 
-	auth, err := auth.NewGoogleAuth(ctx, os.Getenv("GOOGLE_OAUTH2_CLIENT_ID"), os.Getenv("GOOGLE_OAUTH2_CLIENT_SECRET"))
-	if err != nil {
-		return fmt.Errorf("error creating auth: %w", err)
+	var wg sync.WaitGroup
+
+	var eventCache cache.EventCacheRepository
+	var broker live.Broker
+	var sessions auth.SessionStore
+	switch cacheType := os.Getenv("EVENT_CACHE"); cacheType {
+	case "memory":
+		eventCache = cache.NewMemoryEventCache(memoryCacheSize)
+		broker = live.NewMemoryBroker()
+		sessions = auth.NewMemorySessionStore()
+	case "", "redis", "tiered":
+		rdb := redis.NewClient(&redis.Options{
+			Addr: net.JoinHostPort(os.Getenv("REDIS_HOST"), os.Getenv("REDIS_PORT")),
+		})
+		defer rdb.Close()
+		if _, err := rdb.Ping(ctx).Result(); err != nil {
+			return fmt.Errorf("error pinging redis cache: %w", err)
+		}
+		codec, err := cache.CodecByName(os.Getenv("CACHE_CODEC"))
+		if err != nil {
+			return err
+		}
+		redisCache := cache.NewRedisEventCache(rdb, codec)
+		eventCache = redisCache
+		redisBroker := live.NewRedisBroker(rdb)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			redisBroker.Run(ctx)
+		}()
+		broker = redisBroker
+		sessions = auth.NewRedisSessionStore(rdb)
+		if cacheType == "tiered" {
+			tieredCache := cache.NewTieredEventCache(cache.NewMemoryEventCache(memoryCacheSize), redisCache, tieredL1TTL, redisCache)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				tieredCache.Run(ctx)
+			}()
+			eventCache = tieredCache
+		}
+	default:
+		return fmt.Errorf("unknown EVENT_CACHE: %s", cacheType)
 	}
+	slog.Info("using event cache", "type", fmt.Sprintf("%T", eventCache))
 
-	rdb := redis.NewClient(&redis.Options{
-		Addr: net.JoinHostPort(os.Getenv("REDIS_HOST"), os.Getenv("REDIS_PORT")),
-	})
-	defer rdb.Close()
-	if _, err := rdb.Ping(ctx).Result(); err != nil {
-		return fmt.Errorf("error pinging redis cache: %w", err)
+	providerConfigs, err := auth.ProviderConfigsFromEnv(os.Getenv)
+	if err != nil {
+		return fmt.Errorf("error reading auth providers: %w", err)
+	}
+	providers := make([]*auth.Provider, 0, len(providerConfigs))
+	for _, cfg := range providerConfigs {
+		provider, err := auth.NewProvider(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("error creating auth: %w", err)
+		}
+		providers = append(providers, provider)
 	}
-	eventCache := cache.NewRedisEventCache(rdb)
 
 	pgUrl := fmt.Sprintf(
 		"postgres://%s:%s@%s:%s/%s",
@@ -73,9 +131,69 @@ func run(ctx context.Context) error {
 	}
 	defer pool.Close()
 
+	userRepo := users.NewPostgresUsers(pool)
+	accessTokens := auth.NewPostgresAccessTokens(pool)
+	authz := auth.NewAuthorizer(auth.NewPostgresRoles(pool), os.Getenv("CRONJOB_API_KEY"))
+	auth := auth.NewRegistry(sessions, userRepo, accessTokens, providers...)
+
 	eventPicks := picks.NewPostgresEventPicks(pool)
+	fightResolutions := resolutions.NewPostgresFightResolutions(pool)
+
+	resultSource := events.NewLeagueResultSource(map[string]events.ResultSource{model.LeagueUFC: events.NewUFCStatsResultSource()}, events.NewESPNAPIEventScraper())
+	var eventScraper events.EventScraper = events.NewReconcilingEventScraper(events.NewESPNEventScraper(), resultSource, fightResolutions)
+	if oddsKey := os.Getenv("ODDS_API_KEY"); oddsKey != "" {
+		eventScraper = events.NewOddsEventScraper(eventScraper, odds.NewOddsAPIProvider(oddsKey), odds.NewPostgresSnapshots(pool))
+	}
 
-	srv := server.NewServer(auth, events.NewESPNEventScraper(), eventCache, eventPicks)
+	webhookRepo := webhooks.NewPostgresWebhooks(pool)
+	outbox := webhooks.NewOutbox(webhookRepo)
+	webhookWorker := webhooks.NewWorker(webhookRepo)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		webhookWorker.Run(ctx)
+	}()
+
+	eventScraper = events.NewNotifyingEventScraper(eventScraper, eventCache, webhooks.NewDispatchingBroker(broker, outbox))
+
+	preferences := notify.NewPostgresPreferences(pool)
+	channels := make([]notify.Channel, 0)
+	if smtpHost := os.Getenv("SMTP_HOST"); smtpHost != "" {
+		channels = append(channels, notify.NewSMTPChannel(smtpHost, os.Getenv("SMTP_PORT"), os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), os.Getenv("SMTP_FROM")))
+	}
+	if len(channels) > 0 {
+		reminder := events.NewPickReminder(eventScraper, eventCache, eventPicks, preferences, channels, model.Leagues)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reminder.Run(ctx)
+		}()
+	} else {
+		slog.Info("no notification channels configured, pick reminders are disabled")
+	}
+
+	warmer := events.NewCacheWarmer(eventScraper, eventCache, model.Leagues)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		warmer.Run(ctx)
+	}()
+
+	srv := server.NewServer(server.Deps{
+		OAuth:            auth,
+		Authz:            authz,
+		AccessTokens:     accessTokens,
+		EventScraper:     eventScraper,
+		EventCache:       eventCache,
+		EventPicks:       eventPicks,
+		FightResolutions: fightResolutions,
+		Broker:           broker,
+		Webhooks:         webhookRepo,
+		Dispatcher:       outbox,
+		Preferences:      preferences,
+		FeedTokens:       feeds.NewPostgresFeedTokens(pool),
+		Users:            userRepo,
+	})
 	httpServer := &http.Server{
 		Addr:    address,
 		Handler: srv,
@@ -87,7 +205,6 @@ func run(ctx context.Context) error {
 		}
 	}()
 
-	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -1,9 +1,132 @@
 CREATE TABLE IF NOT EXISTS picks (
-  user_id VARCHAR(25) NOT NULL,
+  user_id VARCHAR(255) NOT NULL,
   event_id VARCHAR(25) NOT NULL,
+  league VARCHAR(25) NOT NULL DEFAULT 'ufc',
   picks TEXT[] NOT NULL,
   score SMALLINT,
   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
   PRIMARY KEY (user_id, event_id)
 );
 
+CREATE TABLE IF NOT EXISTS fight_resolutions (
+  event_id VARCHAR(25) NOT NULL,
+  fighters TEXT[] NOT NULL,
+  winner TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (event_id, fighters)
+);
+
+CREATE TABLE IF NOT EXISTS events (
+  id VARCHAR(25) PRIMARY KEY,
+  league VARCHAR(25) NOT NULL,
+  name TEXT NOT NULL,
+  start_time TIMESTAMPTZ NOT NULL,
+  fights JSONB NOT NULL,
+  imported_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS odds_snapshots (
+  event_id VARCHAR(25) NOT NULL,
+  fighter TEXT NOT NULL,
+  price INTEGER NOT NULL,
+  captured_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (event_id, fighter)
+);
+
+CREATE TABLE IF NOT EXISTS webhooks (
+  id SERIAL PRIMARY KEY,
+  url TEXT NOT NULL,
+  league VARCHAR(25),
+  event_types TEXT[] NOT NULL DEFAULT '{}',
+  secret TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  id SERIAL PRIMARY KEY,
+  webhook_id INTEGER NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+  event_type TEXT NOT NULL,
+  payload JSONB NOT NULL,
+  status VARCHAR(10) NOT NULL DEFAULT 'pending',
+  attempts SMALLINT NOT NULL DEFAULT 0,
+  response_status SMALLINT,
+  last_error TEXT,
+  next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  delivered_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+
+CREATE TABLE IF NOT EXISTS notification_preferences (
+  user_id VARCHAR(255) PRIMARY KEY,
+  email TEXT NOT NULL,
+  reminders_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+  hours_before SMALLINT NOT NULL DEFAULT 24,
+  channels TEXT[] NOT NULL DEFAULT '{email}',
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS sent_reminders (
+  user_id VARCHAR(255) NOT NULL,
+  event_id VARCHAR(25) NOT NULL,
+  channel VARCHAR(25) NOT NULL,
+  sent_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (user_id, event_id, channel)
+);
+
+CREATE TABLE IF NOT EXISTS feed_tokens (
+  user_id VARCHAR(255) PRIMARY KEY,
+  token_hash TEXT NOT NULL UNIQUE,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS users (
+  id VARCHAR(255) PRIMARY KEY,
+  email TEXT NOT NULL,
+  name TEXT NOT NULL,
+  display_name TEXT,
+  avatar TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+  user_id VARCHAR(255) NOT NULL,
+  role TEXT NOT NULL,
+  league TEXT NOT NULL DEFAULT '',
+  PRIMARY KEY (user_id, role, league)
+);
+
+CREATE TABLE IF NOT EXISTS access_tokens (
+  id SERIAL PRIMARY KEY,
+  user_id VARCHAR(255) NOT NULL,
+  name TEXT NOT NULL,
+  token_hash TEXT NOT NULL UNIQUE,
+  scopes TEXT[] NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  last_used_at TIMESTAMP,
+  expires_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS access_tokens_user ON access_tokens (user_id);
+
+-- Upgrades databases created before the tables above last changed. The entrypoint only runs
+-- this file on an empty volume, run `make migrate` to apply it to an existing database.
+-- Each upgrade only changes what is out of date, so the file can be applied any number of times.
+ALTER TABLE picks ADD COLUMN IF NOT EXISTS league VARCHAR(25) NOT NULL DEFAULT 'ufc';
+
+-- user IDs grew to fit prefixed provider subjects, each column is only altered while it is
+-- shorter, so running this again does not lock the tables
+DO $$
+DECLARE
+  t TEXT;
+BEGIN
+  FOREACH t IN ARRAY ARRAY['picks', 'notification_preferences', 'sent_reminders', 'feed_tokens'] LOOP
+    IF (SELECT character_maximum_length FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = t AND column_name = 'user_id') < 255 THEN
+      EXECUTE format('ALTER TABLE %I ALTER COLUMN user_id TYPE VARCHAR(255)', t);
+    END IF;
+  END LOOP;
+END
+$$;
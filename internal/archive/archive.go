@@ -0,0 +1,62 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/thebenkogan/ufc/internal/model"
+)
+
+// EventArchiveRepository durably stores past events with their results
+type EventArchiveRepository interface {
+	// GetEvent returns the archived event, or nil if it has not been archived
+	GetEvent(ctx context.Context, id string) (*model.Event, error)
+	SaveEvent(ctx context.Context, event *model.Event) error
+}
+
+type PostgresEventArchive struct {
+	client *pgxpool.Pool
+}
+
+func NewPostgresEventArchive(client *pgxpool.Pool) *PostgresEventArchive {
+	return &PostgresEventArchive{
+		client: client,
+	}
+}
+
+func (p *PostgresEventArchive) GetEvent(ctx context.Context, id string) (*model.Event, error) {
+	rows, _ := p.client.Query(ctx, "SELECT id, league, name, start_time, fights FROM events WHERE id = $1", id)
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+
+	var event model.Event
+	var startTime time.Time
+	var fights []byte
+	if err := rows.Scan(&event.Id, &event.League, &event.Name, &startTime, &fights); err != nil {
+		return nil, err
+	}
+	event.StartTime = startTime.UTC().Format(time.RFC3339)
+	if err := json.Unmarshal(fights, &event.Fights); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (p *PostgresEventArchive) SaveEvent(ctx context.Context, event *model.Event) error {
+	startTime, err := time.Parse(time.RFC3339, event.StartTime)
+	if err != nil {
+		return err
+	}
+	fights, err := json.Marshal(event.Fights)
+	if err != nil {
+		return err
+	}
+	if _, err := p.client.Exec(ctx, "INSERT INTO events (id, league, name, start_time, fights) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (id) DO UPDATE SET league = EXCLUDED.league, name = EXCLUDED.name, start_time = EXCLUDED.start_time, fights = EXCLUDED.fights, imported_at = CURRENT_TIMESTAMP", event.Id, event.League, event.Name, startTime, fights); err != nil {
+		return err
+	}
+	return nil
+}
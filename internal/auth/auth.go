@@ -15,12 +15,23 @@ type OIDCAuth interface {
 	HandleBeginAuth() api.Handler
 	HandleAuthCallback() api.Handler
 	Middleware(h api.Handler) api.Handler
+	// HandleLogout ends the session of the request
+	HandleLogout() api.Handler
+	// HandleLogoutAll ends every session of the logged in user
+	HandleLogoutAll() api.Handler
 }
 
 type User struct {
 	Id    string `json:"sub"`
 	Email string `json:"email"`
 	Name  string `json:"name"`
+	// URL of the user's avatar, if the provider has one
+	Picture string `json:"picture,omitempty"`
+}
+
+// UserStore records users as they log in
+type UserStore interface {
+	UpsertUser(ctx context.Context, user *User) error
 }
 
 func randString(nByte int) (string, error) {
@@ -29,18 +40,24 @@ func randString(nByte int) (string, error) {
 	return base64.RawURLEncoding.EncodeToString(b), nil
 }
 
-func setCookie(w http.ResponseWriter, r *http.Request, name, value string) {
+func setCookie(w http.ResponseWriter, r *http.Request, name, value string, maxAge time.Duration) {
 	c := &http.Cookie{
 		Name:     name,
 		Value:    value,
-		MaxAge:   int(time.Hour.Seconds()),
+		MaxAge:   int(maxAge.Seconds()),
 		Secure:   r.TLS != nil,
 		HttpOnly: true,
+		// cookies are not sent on cross-site subrequests, such as forged form posts
+		SameSite: http.SameSiteLaxMode,
 		Path:     "/",
 	}
 	http.SetCookie(w, c)
 }
 
+func clearCookie(w http.ResponseWriter, r *http.Request, name string) {
+	setCookie(w, r, name, "", -time.Second)
+}
+
 func HandleMe(auth OIDCAuth) api.Handler {
 	handler := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 		api.Encode(w, http.StatusOK, GetUser(ctx))
@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemorySessionStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemorySessionStore()
+
+	now := time.Now()
+	id, err := store.CreateSession(ctx, &Session{User: User{Id: "1"}, CreatedAt: now, RefreshedAt: now})
+	require.NoError(t, err)
+	assert.NotContains(t, store.sessions, id, "sessions should be stored under a hash of their ID")
+
+	session, err := store.GetSession(ctx, id)
+	require.NoError(t, err)
+	require.NotNil(t, session)
+	assert.Equal(t, "1", session.User.Id)
+
+	// the idle expiry slides forward on every read
+	key := hashSessionId(id)
+	stored := store.sessions[key]
+	stored.expiresAt = time.Now().Add(time.Minute)
+	store.sessions[key] = stored
+	_, err = store.GetSession(ctx, id)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(sessionIdleTTL), store.sessions[key].expiresAt, time.Second)
+
+	// idle sessions expire
+	stored = store.sessions[key]
+	stored.expiresAt = time.Now().Add(-time.Minute)
+	store.sessions[key] = stored
+	session, err = store.GetSession(ctx, id)
+	require.NoError(t, err)
+	assert.Nil(t, session)
+
+	// sessions expire after the max age, however active they are
+	old := now.Add(-sessionMaxAge - time.Minute)
+	id, err = store.CreateSession(ctx, &Session{User: User{Id: "1"}, CreatedAt: old, RefreshedAt: now})
+	require.NoError(t, err)
+	session, err = store.GetSession(ctx, id)
+	require.NoError(t, err)
+	assert.Nil(t, session)
+
+	unknown, err := store.GetSession(ctx, "unknown")
+	require.NoError(t, err)
+	assert.Nil(t, unknown)
+
+	// deleted sessions are not recreated by updates
+	id, err = store.CreateSession(ctx, &Session{User: User{Id: "1"}, CreatedAt: now, RefreshedAt: now})
+	require.NoError(t, err)
+	require.NoError(t, store.DeleteUserSessions(ctx, "1"))
+	updated, err := store.UpdateSession(ctx, id, &Session{User: User{Id: "1"}, CreatedAt: now, RefreshedAt: now})
+	require.NoError(t, err)
+	assert.False(t, updated)
+	session, err = store.GetSession(ctx, id)
+	require.NoError(t, err)
+	assert.Nil(t, session)
+}
+
+func TestMiddleware(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemorySessionStore()
+	a := NewRegistry(store, nil, nil)
+
+	now := time.Now()
+	user := User{Id: "1", Email: "user@gmail.com", Name: "user"}
+	id, err := store.CreateSession(ctx, &Session{User: user, CreatedAt: now, RefreshedAt: now})
+	require.NoError(t, err)
+
+	var got *User
+	handler := a.Middleware(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		got = GetUser(ctx)
+		return nil
+	})
+
+	serve := func(cookie *http.Cookie) *httptest.ResponseRecorder {
+		got = nil
+		r := httptest.NewRequest(http.MethodGet, "/me", nil)
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		require.NoError(t, handler(r.Context(), w, r))
+		return w
+	}
+
+	w := serve(&http.Cookie{Name: sessionCookie, Value: id})
+	assert.Equal(t, http.StatusOK, w.Code)
+	require.NotNil(t, got)
+	assert.Equal(t, user, *got)
+
+	w = serve(nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Nil(t, got)
+
+	w = serve(&http.Cookie{Name: sessionCookie, Value: "unknown"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Nil(t, got)
+	// the stale cookie is cleared
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, sessionCookie, cookies[0].Name)
+	assert.Less(t, cookies[0].MaxAge, 0)
+}
+
+func TestLogout(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemorySessionStore()
+	a := NewRegistry(store, nil, nil)
+
+	now := time.Now()
+	create := func(userId string) string {
+		id, err := store.CreateSession(ctx, &Session{User: User{Id: userId}, CreatedAt: now, RefreshedAt: now})
+		require.NoError(t, err)
+		return id
+	}
+	exists := func(id string) bool {
+		session, err := store.GetSession(ctx, id)
+		require.NoError(t, err)
+		return session != nil
+	}
+	serve := func(h func(context.Context, http.ResponseWriter, *http.Request) error, id string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/logout", nil)
+		r.AddCookie(&http.Cookie{Name: sessionCookie, Value: id})
+		w := httptest.NewRecorder()
+		require.NoError(t, h(r.Context(), w, r))
+		return w
+	}
+
+	laptop, phone, other := create("1"), create("1"), create("2")
+
+	w := serve(a.HandleLogout(), laptop)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.False(t, exists(laptop))
+	assert.True(t, exists(phone))
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Less(t, cookies[0].MaxAge, 0)
+
+	tablet := create("1")
+	w = serve(a.HandleLogoutAll(), tablet)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.False(t, exists(phone))
+	assert.False(t, exists(tablet))
+	assert.True(t, exists(other), "other users should stay logged in")
+
+	// logging out everywhere requires a session
+	w = serve(a.HandleLogoutAll(), tablet)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+const defaultRedirectBaseURL = "http://localhost:5173"
+
+var defaultScopes = []string{oidc.ScopeOpenID, "profile", "email"}
+
+// ProviderConfigsFromEnv reads the comma separated providers in OIDC_PROVIDERS, each configured by
+// OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID, OIDC_<NAME>_CLIENT_SECRET and optionally OIDC_<NAME>_SCOPES.
+// Without OIDC_PROVIDERS, Google is configured from GOOGLE_OAUTH2_CLIENT_ID and GOOGLE_OAUTH2_CLIENT_SECRET.
+func ProviderConfigsFromEnv(getenv func(string) string) ([]ProviderConfig, error) {
+	redirectBase := getenv("OIDC_REDIRECT_BASE_URL")
+	if redirectBase == "" {
+		redirectBase = defaultRedirectBaseURL
+	}
+	redirectURL := func(name string) string {
+		return fmt.Sprintf("%s/auth/%s/callback", strings.TrimSuffix(redirectBase, "/"), name)
+	}
+
+	names := getenv("OIDC_PROVIDERS")
+	if names == "" {
+		return []ProviderConfig{{
+			Name:         legacyProvider,
+			Issuer:       "https://accounts.google.com",
+			ClientId:     getenv("GOOGLE_OAUTH2_CLIENT_ID"),
+			ClientSecret: getenv("GOOGLE_OAUTH2_CLIENT_SECRET"),
+			Scopes:       defaultScopes,
+			RedirectURL:  redirectURL(legacyProvider),
+		}}, nil
+	}
+
+	configs := make([]ProviderConfig, 0)
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		config := ProviderConfig{
+			Name:         name,
+			Issuer:       getenv(prefix + "ISSUER"),
+			ClientId:     getenv(prefix + "CLIENT_ID"),
+			ClientSecret: getenv(prefix + "CLIENT_SECRET"),
+			Scopes:       defaultScopes,
+			RedirectURL:  redirectURL(name),
+		}
+		if config.Issuer == "" || config.ClientId == "" {
+			return nil, fmt.Errorf("provider %s needs %sISSUER and %sCLIENT_ID", name, prefix, prefix)
+		}
+		if scopes := strings.Fields(getenv(prefix + "SCOPES")); len(scopes) > 0 {
+			config.Scopes = scopes
+		}
+		configs = append(configs, config)
+	}
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("no providers in OIDC_PROVIDERS")
+	}
+	return configs, nil
+}
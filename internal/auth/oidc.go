@@ -0,0 +1,325 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/thebenkogan/ufc/internal/util/api"
+	"github.com/thebenkogan/ufc/internal/util/logs"
+	"golang.org/x/oauth2"
+)
+
+const sessionCookie = "session_id"
+
+// user IDs predate multiple providers and are the subjects of Google ID tokens,
+// the subjects of other providers are prefixed with their name so they cannot collide
+const legacyProvider = "google"
+
+// ProviderConfig configures an OpenID Connect identity provider
+type ProviderConfig struct {
+	// Name identifies the provider in routes, such as /login/{provider}
+	Name         string
+	Issuer       string
+	ClientId     string
+	ClientSecret string
+	Scopes       []string
+	RedirectURL  string
+}
+
+type Provider struct {
+	name          string
+	config        oauth2.Config
+	tokenVerifier *oidc.IDTokenVerifier
+}
+
+// NewProvider discovers the provider's endpoints from its issuer
+func NewProvider(ctx context.Context, cfg ProviderConfig) (*Provider, error) {
+	provider, err := oidc.NewProvider(ctx, cfg.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover provider %s: %w", cfg.Name, err)
+	}
+	oidcConfig := &oidc.Config{
+		ClientID: cfg.ClientId,
+	}
+	verifier := provider.Verifier(oidcConfig)
+	config := oauth2.Config{
+		ClientID:     cfg.ClientId,
+		ClientSecret: cfg.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  cfg.RedirectURL,
+		Scopes:       cfg.Scopes,
+	}
+	return &Provider{cfg.Name, config, verifier}, nil
+}
+
+// verifyUser verifies the ID token in the oauth2 token and returns its user and nonce
+func (p *Provider) verifyUser(ctx context.Context, token *oauth2.Token) (*User, string, error) {
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, "", fmt.Errorf("no id_token field in oauth2 token")
+	}
+	idToken, err := p.tokenVerifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to verify ID Token: %w", err)
+	}
+
+	var user User
+	if err := idToken.Claims(&user); err != nil {
+		return nil, "", fmt.Errorf("failed to get claims: %w", err)
+	}
+	if p.name != legacyProvider {
+		user.Id = p.name + ":" + user.Id
+	}
+	return &user, idToken.Nonce, nil
+}
+
+// Registry logs users in with any of its providers and keeps them logged in with server-side sessions
+type Registry struct {
+	providers map[string]*Provider
+	// used by /login, which predates multiple providers
+	defaultProvider string
+	sessions        SessionStore
+	users           UserStore
+	tokens          AccessTokenRepository
+}
+
+// NewRegistry creates a registry of the providers, the first is the default
+func NewRegistry(sessions SessionStore, users UserStore, tokens AccessTokenRepository, providers ...*Provider) *Registry {
+	registry := &Registry{
+		providers: make(map[string]*Provider, len(providers)),
+		sessions:  sessions,
+		users:     users,
+		tokens:    tokens,
+	}
+	for _, p := range providers {
+		registry.providers[p.name] = p
+	}
+	if len(providers) > 0 {
+		registry.defaultProvider = providers[0].name
+	}
+	return registry
+}
+
+// Returns the provider in the request path, or the default provider if there is none
+func (a *Registry) provider(w http.ResponseWriter, r *http.Request) (*Provider, bool) {
+	name := r.PathValue("provider")
+	if name == "" {
+		name = a.defaultProvider
+	}
+	provider, ok := a.providers[name]
+	if !ok {
+		http.Error(w, "unknown provider", http.StatusNotFound)
+	}
+	return provider, ok
+}
+
+func (a *Registry) HandleBeginAuth() api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		provider, ok := a.provider(w, r)
+		if !ok {
+			return nil
+		}
+
+		state, err := randString(16)
+		if err != nil {
+			return err
+		}
+		nonce, err := randString(16)
+		if err != nil {
+			return err
+		}
+		setCookie(w, r, "state", state, time.Hour)
+		setCookie(w, r, "nonce", nonce, time.Hour)
+		// Google issues a refresh token for offline access the first time the user consents,
+		// other providers do when offline_access is in the scopes
+		http.Redirect(w, r, provider.config.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.AccessTypeOffline), http.StatusFound)
+		return nil
+	}
+}
+
+func (a *Registry) HandleAuthCallback() api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		provider, ok := a.provider(w, r)
+		if !ok {
+			return nil
+		}
+
+		state, err := r.Cookie("state")
+		if err != nil {
+			http.Error(w, "state not found", http.StatusBadRequest)
+			return nil
+		}
+		if r.URL.Query().Get("state") != state.Value {
+			http.Error(w, "state did not match", http.StatusBadRequest)
+			return nil
+		}
+
+		oauth2Token, err := provider.config.Exchange(ctx, r.URL.Query().Get("code"))
+		if err != nil {
+			return fmt.Errorf("failed to exchange token: %w", err)
+		}
+		user, tokenNonce, err := provider.verifyUser(ctx, oauth2Token)
+		if err != nil {
+			return err
+		}
+
+		nonce, err := r.Cookie("nonce")
+		if err != nil {
+			http.Error(w, "nonce not found", http.StatusBadRequest)
+			return nil
+		}
+		if tokenNonce != nonce.Value {
+			http.Error(w, "nonce did not match", http.StatusBadRequest)
+			return nil
+		}
+
+		if err := a.users.UpsertUser(ctx, user); err != nil {
+			return fmt.Errorf("failed to save user: %w", err)
+		}
+
+		now := time.Now()
+		sessionId, err := a.sessions.CreateSession(ctx, &Session{
+			User:         *user,
+			Provider:     provider.name,
+			RefreshToken: oauth2Token.RefreshToken,
+			CreatedAt:    now,
+			RefreshedAt:  now,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create session: %w", err)
+		}
+
+		setCookie(w, r, sessionCookie, sessionId, sessionMaxAge)
+		clearCookie(w, r, "state")
+		clearCookie(w, r, "nonce")
+
+		return nil
+	}
+}
+
+func (a *Registry) HandleLogout() api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		if cookie, err := r.Cookie(sessionCookie); err == nil {
+			if err := a.sessions.DeleteSession(ctx, cookie.Value); err != nil {
+				return fmt.Errorf("failed to delete session: %w", err)
+			}
+		}
+		clearCookie(w, r, sessionCookie)
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+}
+
+func (a *Registry) HandleLogoutAll() api.Handler {
+	return a.Middleware(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		user := GetUser(ctx)
+		if err := a.sessions.DeleteUserSessions(ctx, user.Id); err != nil {
+			return fmt.Errorf("failed to delete user sessions: %w", err)
+		}
+		clearCookie(w, r, sessionCookie)
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+}
+
+// errSessionEnded is returned when a session is deleted while it is being refreshed
+var errSessionEnded = errors.New("session ended during refresh")
+
+// refreshSession re-validates the user with their provider and updates their details in the session.
+// Providers may leave the ID token out of refresh responses, then the stored details are kept.
+func (a *Registry) refreshSession(ctx context.Context, provider *Provider, sessionId string, session *Session) error {
+	token, err := provider.config.TokenSource(ctx, &oauth2.Token{RefreshToken: session.RefreshToken}).Token()
+	if err != nil {
+		return err
+	}
+	if _, ok := token.Extra("id_token").(string); ok {
+		user, _, err := provider.verifyUser(ctx, token)
+		if err != nil {
+			return err
+		}
+		if err := a.users.UpsertUser(ctx, user); err != nil {
+			return fmt.Errorf("failed to save user: %w", err)
+		}
+		session.User = *user
+	}
+
+	session.RefreshedAt = time.Now()
+	if token.RefreshToken != "" {
+		session.RefreshToken = token.RefreshToken
+	}
+	updated, err := a.sessions.UpdateSession(ctx, sessionId, session)
+	if err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+	if !updated {
+		return errSessionEnded
+	}
+	return nil
+}
+
+func (a *Registry) Middleware(h api.Handler) api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		if raw, ok := bearerToken(r); ok {
+			return a.authenticateToken(ctx, w, r, raw, h)
+		}
+
+		cookie, err := r.Cookie(sessionCookie)
+		if err == http.ErrNoCookie {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return nil
+		}
+
+		session, err := a.sessions.GetSession(ctx, cookie.Value)
+		if err != nil {
+			return fmt.Errorf("failed to get session: %w", err)
+		}
+		if session == nil {
+			clearCookie(w, r, sessionCookie)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return nil
+		}
+
+		// sessions of providers that are no longer configured are kept until they expire
+		if provider, ok := a.providers[session.Provider]; ok && session.needsRefresh() {
+			if err := a.refreshSession(ctx, provider, cookie.Value, session); err != nil {
+				if errors.Is(err, errSessionEnded) {
+					// the user logged out while the session was refreshed
+					clearCookie(w, r, sessionCookie)
+					http.Error(w, "unauthorized", http.StatusUnauthorized)
+					return nil
+				}
+				var retrieveErr *oauth2.RetrieveError
+				if errors.As(err, &retrieveErr) && retrieveErr.ErrorCode == "invalid_grant" {
+					// the user revoked access or the refresh token expired, they must log in again
+					if err := a.sessions.DeleteSession(ctx, cookie.Value); err != nil {
+						return fmt.Errorf("failed to delete session: %w", err)
+					}
+					clearCookie(w, r, sessionCookie)
+					http.Error(w, "unauthorized", http.StatusUnauthorized)
+					return nil
+				}
+				// keep the session if the provider is unavailable,
+				// it is retried once sessionRefreshInterval has passed again
+				logs.Logger(ctx).Warn("failed to refresh session", "provider", provider.name, "error", err)
+				session.RefreshedAt = time.Now()
+				updated, err := a.sessions.UpdateSession(ctx, cookie.Value, session)
+				if err != nil {
+					logs.Logger(ctx).Warn("failed to postpone session refresh", "error", err)
+				} else if !updated {
+					clearCookie(w, r, sessionCookie)
+					http.Error(w, "unauthorized", http.StatusUnauthorized)
+					return nil
+				}
+			}
+		}
+
+		ctx = WithUser(ctx, &session.User)
+		rWithUser := r.WithContext(ctx)
+
+		return h(ctx, w, rWithUser)
+	}
+}
@@ -0,0 +1,452 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thebenkogan/ufc/internal/util/api"
+)
+
+const testClientId = "client"
+
+// testIssuer is a minimal OpenID Connect provider that issues ID tokens for a single user
+type testIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu sync.Mutex
+	// nonce of the ID token issued for an authorization code
+	nonce string
+	// name claim of issued ID tokens
+	name string
+	// refresh tokens are rejected once revoked
+	revoked bool
+	// called while a refresh token is being exchanged, if set
+	onRefresh func()
+	// the token endpoint fails while unavailable
+	unavailable bool
+	// refresh responses leave out the ID token, as some providers do
+	omitRefreshIDToken bool
+	// number of requests to the token endpoint
+	tokenRequests int
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	issuer := &testIssuer{key: key, name: "Test User"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		api.Encode(w, http.StatusOK, map[string]any{
+			"issuer":                                issuer.server.URL,
+			"authorization_endpoint":                issuer.server.URL + "/authorize",
+			"token_endpoint":                        issuer.server.URL + "/token",
+			"jwks_uri":                              issuer.server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		api.Encode(w, http.StatusOK, jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{
+			Key: &key.PublicKey, KeyID: "test", Algorithm: string(jose.RS256), Use: "sig",
+		}}})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		issuer.mu.Lock()
+		defer issuer.mu.Unlock()
+
+		issuer.tokenRequests++
+		if issuer.unavailable {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		token := map[string]any{
+			"access_token":  "access",
+			"token_type":    "Bearer",
+			"expires_in":    3600,
+			"refresh_token": "refresh",
+		}
+		nonce := ""
+		switch r.FormValue("grant_type") {
+		case "authorization_code":
+			if r.FormValue("code") != "code" {
+				api.Encode(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+				return
+			}
+			nonce = issuer.nonce
+		case "refresh_token":
+			if issuer.revoked || r.FormValue("refresh_token") != "refresh" {
+				api.Encode(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+				return
+			}
+			if issuer.onRefresh != nil {
+				issuer.onRefresh()
+			}
+			if issuer.omitRefreshIDToken {
+				api.Encode(w, http.StatusOK, token)
+				return
+			}
+		}
+
+		token["id_token"] = issuer.idToken(t, nonce)
+		api.Encode(w, http.StatusOK, token)
+	})
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+func (i *testIssuer) idToken(t *testing.T, nonce string) string {
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: i.key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "test"),
+	)
+	require.NoError(t, err)
+
+	now := time.Now()
+	claims := map[string]any{
+		"iss":     i.server.URL,
+		"sub":     "123",
+		"aud":     testClientId,
+		"iat":     now.Unix(),
+		"exp":     now.Add(time.Hour).Unix(),
+		"email":   "user@example.com",
+		"name":    i.name,
+		"picture": "https://example.com/avatar.png",
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	signed, err := signer.Sign(payload)
+	require.NoError(t, err)
+	token, err := signed.CompactSerialize()
+	require.NoError(t, err)
+	return token
+}
+
+type testUsers struct {
+	upserted []User
+}
+
+func (u *testUsers) UpsertUser(_ context.Context, user *User) error {
+	u.upserted = append(u.upserted, *user)
+	return nil
+}
+
+func TestRegistry(t *testing.T) {
+	ctx := context.Background()
+	issuer := newTestIssuer(t)
+
+	provider, err := NewProvider(ctx, ProviderConfig{
+		Name:         "mock",
+		Issuer:       issuer.server.URL,
+		ClientId:     testClientId,
+		ClientSecret: "secret",
+		Scopes:       defaultScopes,
+		RedirectURL:  "http://localhost:5173/auth/mock/callback",
+	})
+	require.NoError(t, err)
+	store := NewMemorySessionStore()
+	users := &testUsers{}
+	registry := NewRegistry(store, users, nil, provider)
+
+	handle := func(h api.Handler) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			require.NoError(t, h(r.Context(), w, r))
+		}
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/login", handle(registry.HandleBeginAuth()))
+	mux.Handle("/login/{provider}", handle(registry.HandleBeginAuth()))
+	mux.Handle("/auth/{provider}/callback", handle(registry.HandleAuthCallback()))
+	mux.Handle("/me", handle(HandleMe(registry)))
+
+	serve := func(target string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+	cookie := func(w *httptest.ResponseRecorder, name string) *http.Cookie {
+		for _, c := range w.Result().Cookies() {
+			if c.Name == name {
+				return c
+			}
+		}
+		return nil
+	}
+	me := func(session *http.Cookie) (int, *User) {
+		w := serve("/me", session)
+		if w.Code != http.StatusOK {
+			return w.Code, nil
+		}
+		var user User
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&user))
+		return w.Code, &user
+	}
+
+	w := serve("/login/unknown")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// /login uses the default provider
+	w = serve("/login")
+	assert.Equal(t, http.StatusFound, w.Code)
+
+	w = serve("/login/mock")
+	require.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(location.String(), issuer.server.URL+"/authorize"))
+	assert.Equal(t, testClientId, location.Query().Get("client_id"))
+	state, nonce := cookie(w, "state"), cookie(w, "nonce")
+	require.NotNil(t, state)
+	require.NotNil(t, nonce)
+	assert.Equal(t, state.Value, location.Query().Get("state"))
+	assert.Equal(t, nonce.Value, location.Query().Get("nonce"))
+
+	// the user logs in at the provider, which redirects back with a code
+	issuer.mu.Lock()
+	issuer.nonce = nonce.Value
+	issuer.mu.Unlock()
+
+	w = serve("/auth/mock/callback?code=code&state=wrong", state, nonce)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = serve("/auth/mock/callback?code=code&state="+state.Value, state, &http.Cookie{Name: "nonce", Value: "wrong"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve("/auth/mock/callback?code=code&state="+state.Value, state, nonce)
+	require.Equal(t, http.StatusOK, w.Code)
+	session := cookie(w, sessionCookie)
+	require.NotNil(t, session)
+	assert.Equal(t, int(sessionMaxAge.Seconds()), session.MaxAge)
+	assert.Equal(t, http.SameSiteLaxMode, session.SameSite)
+	assert.True(t, session.HttpOnly)
+
+	code, user := me(session)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, User{Id: "mock:123", Email: "user@example.com", Name: "Test User", Picture: "https://example.com/avatar.png"}, *user)
+	assert.Equal(t, []User{*user}, users.upserted)
+
+	stored, err := store.GetSession(ctx, session.Value)
+	require.NoError(t, err)
+	assert.Equal(t, "mock", stored.Provider)
+	assert.Equal(t, "refresh", stored.RefreshToken)
+
+	// stale sessions pick up changes to the user from the provider
+	issuer.mu.Lock()
+	issuer.name = "Renamed User"
+	issuer.mu.Unlock()
+	stored.RefreshedAt = time.Now().Add(-2 * sessionRefreshInterval)
+	_, err = store.UpdateSession(ctx, session.Value, stored)
+	require.NoError(t, err)
+	code, user = me(session)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "Renamed User", user.Name)
+	require.Len(t, users.upserted, 2)
+	assert.Equal(t, "Renamed User", users.upserted[1].Name)
+
+	// fresh sessions are not refreshed
+	issuer.mu.Lock()
+	issuer.revoked = true
+	issuer.mu.Unlock()
+	code, _ = me(session)
+	assert.Equal(t, http.StatusOK, code)
+
+	// stale sessions end once the provider revokes the refresh token
+	stored, err = store.GetSession(ctx, session.Value)
+	require.NoError(t, err)
+	stored.RefreshedAt = time.Now().Add(-2 * sessionRefreshInterval)
+	_, err = store.UpdateSession(ctx, session.Value, stored)
+	require.NoError(t, err)
+	code, _ = me(session)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	stored, err = store.GetSession(ctx, session.Value)
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+}
+
+func TestLogoutDuringRefresh(t *testing.T) {
+	ctx := context.Background()
+	issuer := newTestIssuer(t)
+
+	provider, err := NewProvider(ctx, ProviderConfig{
+		Name:         "mock",
+		Issuer:       issuer.server.URL,
+		ClientId:     testClientId,
+		ClientSecret: "secret",
+		Scopes:       defaultScopes,
+		RedirectURL:  "http://localhost:5173/auth/mock/callback",
+	})
+	require.NoError(t, err)
+	store := NewMemorySessionStore()
+	registry := NewRegistry(store, &testUsers{}, nil, provider)
+
+	now := time.Now()
+	sessionId, err := store.CreateSession(ctx, &Session{
+		User:         User{Id: "mock:123"},
+		Provider:     "mock",
+		RefreshToken: "refresh",
+		CreatedAt:    now,
+		RefreshedAt:  now.Add(-2 * sessionRefreshInterval),
+	})
+	require.NoError(t, err)
+
+	// the user logs out everywhere while their session is being refreshed
+	issuer.onRefresh = func() {
+		require.NoError(t, store.DeleteUserSessions(ctx, "mock:123"))
+	}
+
+	reached := false
+	h := registry.Middleware(func(_ context.Context, _ http.ResponseWriter, _ *http.Request) error {
+		reached = true
+		return nil
+	})
+	r := httptest.NewRequest(http.MethodGet, "/me", nil)
+	r.AddCookie(&http.Cookie{Name: sessionCookie, Value: sessionId})
+	w := httptest.NewRecorder()
+	require.NoError(t, h(ctx, w, r))
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.False(t, reached)
+	session, err := store.GetSession(ctx, sessionId)
+	require.NoError(t, err)
+	assert.Nil(t, session, "the refresh should not recreate the deleted session")
+}
+
+func TestRefreshFailures(t *testing.T) {
+	ctx := context.Background()
+	issuer := newTestIssuer(t)
+
+	provider, err := NewProvider(ctx, ProviderConfig{
+		Name:         "mock",
+		Issuer:       issuer.server.URL,
+		ClientId:     testClientId,
+		ClientSecret: "secret",
+		Scopes:       defaultScopes,
+		RedirectURL:  "http://localhost:5173/auth/mock/callback",
+	})
+	require.NoError(t, err)
+	store := NewMemorySessionStore()
+	users := &testUsers{}
+	registry := NewRegistry(store, users, nil, provider)
+
+	user := User{Id: "mock:123", Name: "Stored User"}
+	sessionId, err := store.CreateSession(ctx, &Session{
+		User:         user,
+		Provider:     "mock",
+		RefreshToken: "refresh",
+		CreatedAt:    time.Now(),
+		RefreshedAt:  time.Now().Add(-2 * sessionRefreshInterval),
+	})
+	require.NoError(t, err)
+
+	h := registry.Middleware(func(ctx context.Context, w http.ResponseWriter, _ *http.Request) error {
+		api.Encode(w, http.StatusOK, GetUser(ctx))
+		return nil
+	})
+	me := func() (int, *User) {
+		r := httptest.NewRequest(http.MethodGet, "/me", nil)
+		r.AddCookie(&http.Cookie{Name: sessionCookie, Value: sessionId})
+		w := httptest.NewRecorder()
+		require.NoError(t, h(ctx, w, r))
+		var got User
+		_ = json.NewDecoder(w.Body).Decode(&got)
+		return w.Code, &got
+	}
+
+	// an unavailable provider keeps the session, but is only retried after the refresh interval
+	issuer.mu.Lock()
+	issuer.unavailable = true
+	issuer.mu.Unlock()
+	code, got := me()
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, user, *got)
+	issuer.mu.Lock()
+	requests := issuer.tokenRequests
+	issuer.mu.Unlock()
+	for range 2 {
+		code, _ := me()
+		require.Equal(t, http.StatusOK, code)
+	}
+	assert.Equal(t, requests, issuer.tokenRequests)
+	stored, err := store.GetSession(ctx, sessionId)
+	require.NoError(t, err)
+	assert.False(t, stored.needsRefresh())
+
+	// refresh responses without an ID token keep the stored user
+	issuer.mu.Lock()
+	issuer.unavailable = false
+	issuer.omitRefreshIDToken = true
+	issuer.mu.Unlock()
+	stored.RefreshedAt = time.Now().Add(-2 * sessionRefreshInterval)
+	_, err = store.UpdateSession(ctx, sessionId, stored)
+	require.NoError(t, err)
+	code, got = me()
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, user, *got)
+	assert.Greater(t, issuer.tokenRequests, requests)
+	assert.Empty(t, users.upserted)
+	stored, err = store.GetSession(ctx, sessionId)
+	require.NoError(t, err)
+	assert.False(t, stored.needsRefresh())
+}
+
+func TestProviderConfigsFromEnv(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(key string) string { return vars[key] }
+	}
+
+	configs, err := ProviderConfigsFromEnv(env(map[string]string{
+		"GOOGLE_OAUTH2_CLIENT_ID":     "google-id",
+		"GOOGLE_OAUTH2_CLIENT_SECRET": "google-secret",
+	}))
+	require.NoError(t, err)
+	require.Len(t, configs, 1)
+	assert.Equal(t, "google", configs[0].Name)
+	assert.Equal(t, "https://accounts.google.com", configs[0].Issuer)
+	assert.Equal(t, "google-id", configs[0].ClientId)
+	assert.Equal(t, "http://localhost:5173/auth/google/callback", configs[0].RedirectURL)
+
+	configs, err = ProviderConfigsFromEnv(env(map[string]string{
+		"OIDC_PROVIDERS":          "google, Okta",
+		"OIDC_REDIRECT_BASE_URL":  "https://picks.example.com/",
+		"OIDC_GOOGLE_ISSUER":      "https://accounts.google.com",
+		"OIDC_GOOGLE_CLIENT_ID":   "google-id",
+		"OIDC_OKTA_ISSUER":        "https://example.okta.com",
+		"OIDC_OKTA_CLIENT_ID":     "okta-id",
+		"OIDC_OKTA_CLIENT_SECRET": "okta-secret",
+		"OIDC_OKTA_SCOPES":        "openid email offline_access",
+	}))
+	require.NoError(t, err)
+	require.Len(t, configs, 2)
+	assert.Equal(t, defaultScopes, configs[0].Scopes)
+	assert.Equal(t, ProviderConfig{
+		Name:         "okta",
+		Issuer:       "https://example.okta.com",
+		ClientId:     "okta-id",
+		ClientSecret: "okta-secret",
+		Scopes:       []string{"openid", "email", "offline_access"},
+		RedirectURL:  "https://picks.example.com/auth/okta/callback",
+	}, configs[1])
+
+	_, err = ProviderConfigsFromEnv(env(map[string]string{"OIDC_PROVIDERS": "okta"}))
+	assert.Error(t, err)
+}
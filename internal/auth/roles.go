@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"slices"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/thebenkogan/ufc/internal/model"
+	"github.com/thebenkogan/ufc/internal/util/api"
+	"github.com/thebenkogan/ufc/internal/util/logs"
+)
+
+type Role string
+
+const (
+	// admins can do anything in every league
+	RoleAdmin Role = "admin"
+	// commissioners manage a single league, such as resolving disputed fights
+	RoleCommissioner Role = "commissioner"
+	// every logged in user is a member, it is never granted
+	RoleMember Role = "member"
+)
+
+// Grant gives a user a role, in a league for league roles
+type Grant struct {
+	Role   Role   `db:"role" json:"role"`
+	League string `db:"league" json:"league,omitempty"`
+}
+
+// allows reports whether the grant permits acting as the role in the league
+func (g Grant) allows(role Role, league string) bool {
+	switch g.Role {
+	case RoleAdmin:
+		return true
+	case RoleCommissioner:
+		return role != RoleAdmin && g.League == league
+	}
+	return false
+}
+
+func (g Grant) validate() error {
+	switch g.Role {
+	case RoleAdmin:
+		if g.League != "" {
+			return fmt.Errorf("admin is not a league role")
+		}
+	case RoleCommissioner:
+		if !slices.Contains(model.Leagues, g.League) {
+			return fmt.Errorf("unknown league: %q", g.League)
+		}
+	default:
+		return fmt.Errorf("role cannot be granted: %q", g.Role)
+	}
+	return nil
+}
+
+type RoleRepository interface {
+	GetGrants(ctx context.Context, userId string) ([]Grant, error)
+	// SetGrants replaces all grants of the user
+	SetGrants(ctx context.Context, userId string, grants []Grant) error
+}
+
+type PostgresRoles struct {
+	client *pgxpool.Pool
+}
+
+func NewPostgresRoles(client *pgxpool.Pool) *PostgresRoles {
+	return &PostgresRoles{
+		client: client,
+	}
+}
+
+func (p *PostgresRoles) GetGrants(ctx context.Context, userId string) ([]Grant, error) {
+	rows, _ := p.client.Query(ctx, "SELECT role, league FROM user_roles WHERE user_id = $1", userId)
+	return pgx.CollectRows(rows, pgx.RowToStructByName[Grant])
+}
+
+func (p *PostgresRoles) SetGrants(ctx context.Context, userId string, grants []Grant) error {
+	return pgx.BeginFunc(ctx, p.client, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "DELETE FROM user_roles WHERE user_id = $1", userId); err != nil {
+			return err
+		}
+		for _, g := range grants {
+			if _, err := tx.Exec(ctx, "INSERT INTO user_roles (user_id, role, league) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING", userId, g.Role, g.League); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ServiceUser is the principal of requests made with the service API key, such as scheduled jobs
+var ServiceUser = User{Id: "service", Name: "service"}
+
+// Authorizer decides what the user of a request may do
+type Authorizer struct {
+	roles RoleRepository
+	// requests with this key in the api-key header act as the service user, empty disables it
+	serviceKey string
+}
+
+func NewAuthorizer(roles RoleRepository, serviceKey string) *Authorizer {
+	return &Authorizer{
+		roles:      roles,
+		serviceKey: serviceKey,
+	}
+}
+
+func (a *Authorizer) isService(r *http.Request) bool {
+	key := r.Header.Get("api-key")
+	return a.serviceKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(a.serviceKey)) == 1
+}
+
+// Authenticate lets requests with the service API key through as the service user,
+// and otherwise requires a user logged in with oauth
+func (a *Authorizer) Authenticate(oauth OIDCAuth) func(api.Handler) api.Handler {
+	return func(h api.Handler) api.Handler {
+		loggedIn := oauth.Middleware(h)
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			if a.isService(r) {
+				user := ServiceUser
+				ctx = WithUser(ctx, &user)
+				return h(ctx, w, r.WithContext(ctx))
+			}
+			return loggedIn(ctx, w, r)
+		}
+	}
+}
+
+// Returns the league a request acts in, the default league for routes without one
+func requestLeague(r *http.Request) string {
+	if league := r.PathValue("league"); league != "" {
+		return league
+	}
+	return model.LeagueUFC
+}
+
+// Require allows only users with the role, in the league of the request for league roles.
+// It must be wrapped by a middleware that puts the user in the context.
+func (a *Authorizer) Require(role Role, h api.Handler) api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		user := GetUser(ctx)
+		if user == nil {
+			return fmt.Errorf("no user in context")
+		}
+
+		allowed, err := a.allows(ctx, user, role, requestLeague(r))
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			logs.Logger(ctx).Warn("forbidden", "user", user.Id, "role", role)
+			api.Encode(w, http.StatusForbidden, http.StatusText(http.StatusForbidden))
+			return nil
+		}
+		return h(ctx, w, r)
+	}
+}
+
+func (a *Authorizer) allows(ctx context.Context, user *User, role Role, league string) (bool, error) {
+	if role == RoleMember || user.Id == ServiceUser.Id {
+		return true, nil
+	}
+	grants, err := a.roles.GetGrants(ctx, user.Id)
+	if err != nil {
+		return false, fmt.Errorf("error getting grants: %w", err)
+	}
+	return slices.ContainsFunc(grants, func(g Grant) bool {
+		return g.allows(role, league)
+	}), nil
+}
+
+func (a *Authorizer) HandleGetGrants() api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		grants, err := a.roles.GetGrants(ctx, r.PathValue("id"))
+		if err != nil {
+			return fmt.Errorf("error getting grants: %w", err)
+		}
+		api.Encode(w, http.StatusOK, grants)
+		return nil
+	}
+}
+
+func (a *Authorizer) HandlePutGrants() api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		var grants []Grant
+		api.Decode(r, &grants)
+
+		for _, g := range grants {
+			if err := g.validate(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return nil
+			}
+		}
+
+		userId := r.PathValue("id")
+		if err := a.roles.SetGrants(ctx, userId, grants); err != nil {
+			return fmt.Errorf("error setting grants: %w", err)
+		}
+		if grants == nil {
+			grants = []Grant{}
+		}
+
+		api.Encode(w, http.StatusOK, grants)
+		return nil
+	}
+}
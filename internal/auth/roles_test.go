@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thebenkogan/ufc/internal/util/api"
+)
+
+type testRoles struct {
+	grants map[string][]Grant
+}
+
+func (r *testRoles) GetGrants(_ context.Context, userId string) ([]Grant, error) {
+	return r.grants[userId], nil
+}
+
+func (r *testRoles) SetGrants(_ context.Context, userId string, grants []Grant) error {
+	r.grants[userId] = grants
+	return nil
+}
+
+// testLogin logs in the user in the user-id header, if any
+type testLogin struct{ OIDCAuth }
+
+func (testLogin) Middleware(h api.Handler) api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		userId := r.Header.Get("user-id")
+		if userId == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return nil
+		}
+		ctx = WithUser(ctx, &User{Id: userId})
+		return h(ctx, w, r.WithContext(ctx))
+	}
+}
+
+func TestAuthorizer(t *testing.T) {
+	roles := &testRoles{grants: map[string][]Grant{
+		"admin":        {{Role: RoleAdmin}},
+		"commissioner": {{Role: RoleCommissioner, League: "pfl"}},
+	}}
+	authz := NewAuthorizer(roles, "secret")
+	authenticate := authz.Authenticate(testLogin{})
+
+	mux := http.NewServeMux()
+	for _, role := range []Role{RoleAdmin, RoleCommissioner, RoleMember} {
+		h := authenticate(authz.Require(role, func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			_, _ = w.Write([]byte(GetUser(ctx).Id))
+			return nil
+		}))
+		for _, pattern := range []string{"/" + string(role), "/leagues/{league}/" + string(role)} {
+			mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+				require.NoError(t, h(r.Context(), w, r))
+			})
+		}
+	}
+
+	authzTests := []struct {
+		name     string
+		path     string
+		userId   string
+		apiKey   string
+		expected int
+	}{
+		{"no user", "/member", "", "", http.StatusUnauthorized},
+		{"wrong key", "/admin", "", "wrong", http.StatusUnauthorized},
+		{"service", "/admin", "", "secret", http.StatusOK},
+		{"member as member", "/member", "member", "", http.StatusOK},
+		{"member as admin", "/admin", "member", "", http.StatusForbidden},
+		{"member as commissioner", "/leagues/pfl/commissioner", "member", "", http.StatusForbidden},
+		{"commissioner in league", "/leagues/pfl/commissioner", "commissioner", "", http.StatusOK},
+		{"commissioner in other league", "/leagues/bellator/commissioner", "commissioner", "", http.StatusForbidden},
+		{"commissioner in default league", "/commissioner", "commissioner", "", http.StatusForbidden},
+		{"commissioner as admin", "/admin", "commissioner", "", http.StatusForbidden},
+		{"admin as commissioner", "/leagues/bellator/commissioner", "admin", "", http.StatusOK},
+		{"admin as admin", "/admin", "admin", "", http.StatusOK},
+	}
+
+	for _, tt := range authzTests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			if tt.userId != "" {
+				r.Header.Set("user-id", tt.userId)
+			}
+			if tt.apiKey != "" {
+				r.Header.Set("api-key", tt.apiKey)
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			assert.Equal(t, tt.expected, w.Code)
+		})
+	}
+
+	// the service key is disabled when empty
+	r := httptest.NewRequest(http.MethodPost, "/admin", nil)
+	r.Header.Set("api-key", "")
+	w := httptest.NewRecorder()
+	require.NoError(t, NewAuthorizer(roles, "").Authenticate(testLogin{})(func(_ context.Context, w http.ResponseWriter, _ *http.Request) error {
+		w.WriteHeader(http.StatusOK)
+		return nil
+	})(r.Context(), w, r))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestHandlePutGrants(t *testing.T) {
+	roles := &testRoles{grants: map[string][]Grant{}}
+	authz := NewAuthorizer(roles, "")
+
+	put := func(body string) int {
+		r := httptest.NewRequest(http.MethodPut, "/admin/users/1/roles", strings.NewReader(body))
+		r.SetPathValue("id", "1")
+		w := httptest.NewRecorder()
+		require.NoError(t, authz.HandlePutGrants()(r.Context(), w, r))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusBadRequest, put(`[{"role": "owner"}]`))
+	assert.Equal(t, http.StatusBadRequest, put(`[{"role": "admin", "league": "ufc"}]`))
+	assert.Equal(t, http.StatusBadRequest, put(`[{"role": "commissioner", "league": "nba"}]`))
+	assert.Equal(t, http.StatusBadRequest, put(`[{"role": "member"}]`))
+	assert.Empty(t, roles.grants["1"])
+
+	assert.Equal(t, http.StatusOK, put(`[{"role": "admin"}, {"role": "commissioner", "league": "pfl"}]`))
+	assert.Equal(t, []Grant{{Role: RoleAdmin}, {Role: RoleCommissioner, League: "pfl"}}, roles.grants["1"])
+}
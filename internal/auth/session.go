@@ -0,0 +1,269 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// sessions expire after this long without a request
+	sessionIdleTTL = 7 * 24 * time.Hour
+	// sessions expire this long after login, however active they are
+	sessionMaxAge = 30 * 24 * time.Hour
+	// how often the user is re-validated with the identity provider using the refresh token
+	sessionRefreshInterval = time.Hour
+)
+
+// Session is a logged in user, identified by an opaque ID in the session cookie
+type Session struct {
+	User User `json:"user"`
+	// name of the provider the user logged in with
+	Provider string `json:"provider"`
+	// empty if the provider did not issue one, then the session is never refreshed
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	// when the user was last validated with the identity provider
+	RefreshedAt time.Time `json:"refreshed_at"`
+}
+
+func (s *Session) isExpired() bool {
+	return time.Since(s.CreatedAt) > sessionMaxAge
+}
+
+func (s *Session) needsRefresh() bool {
+	return s.RefreshToken != "" && time.Since(s.RefreshedAt) > sessionRefreshInterval
+}
+
+type SessionStore interface {
+	// CreateSession stores the session and returns its new ID
+	CreateSession(ctx context.Context, session *Session) (string, error)
+	// GetSession returns the session and slides its idle expiry, or nil if it does not exist
+	GetSession(ctx context.Context, id string) (*Session, error)
+	// UpdateSession replaces a session after it was refreshed. Returns false without storing it
+	// if the session no longer exists, such as when the user logged out during the refresh.
+	UpdateSession(ctx context.Context, id string, session *Session) (bool, error)
+	DeleteSession(ctx context.Context, id string) error
+	// DeleteUserSessions deletes every session of the user, logging them out everywhere
+	DeleteUserSessions(ctx context.Context, userId string) error
+}
+
+func newSessionId() (string, error) {
+	return randString(32)
+}
+
+// sessions are stored under a hash of their ID, so the store never holds usable session IDs
+func hashSessionId(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])
+}
+
+type RedisSessionStore struct {
+	client *redis.Client
+}
+
+func NewRedisSessionStore(client *redis.Client) *RedisSessionStore {
+	return &RedisSessionStore{
+		client: client,
+	}
+}
+
+func (_ *RedisSessionStore) key(id string) string {
+	return sessionKey(hashSessionId(id))
+}
+
+func sessionKey(hashedId string) string {
+	return "session#" + hashedId
+}
+
+// the set of a user's hashed session IDs, so they can all be deleted
+func userSessionsKey(userId string) string {
+	return "user_sessions#" + userId
+}
+
+func (r *RedisSessionStore) CreateSession(ctx context.Context, session *Session) (string, error) {
+	id, err := newSessionId()
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+	userKey := userSessionsKey(session.User.Id)
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, r.key(id), data, sessionIdleTTL)
+		pipe.SAdd(ctx, userKey, hashSessionId(id))
+		// no session of the user outlives the set
+		pipe.Expire(ctx, userKey, sessionMaxAge)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func (r *RedisSessionStore) GetSession(ctx context.Context, id string) (*Session, error) {
+	data, err := r.client.GetEx(ctx, r.key(id), sessionIdleTTL).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, err
+	}
+	if session.isExpired() {
+		return nil, r.DeleteSession(ctx, id)
+	}
+	return &session, nil
+}
+
+func (r *RedisSessionStore) UpdateSession(ctx context.Context, id string, session *Session) (bool, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return false, err
+	}
+	// only replace the session if it exists, a deleted session must stay deleted
+	return r.client.SetXX(ctx, r.key(id), data, sessionIdleTTL).Result()
+}
+
+// attempts at deleting a session that keeps being updated while it is deleted
+const deleteSessionAttempts = 3
+
+func (r *RedisSessionStore) DeleteSession(ctx context.Context, id string) error {
+	key := r.key(id)
+	// the session is read for its user, and deleted only if it did not change since
+	deleteSession := func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, key).Bytes()
+		if err == redis.Nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var session Session
+		if err := json.Unmarshal(data, &session); err != nil {
+			return tx.Del(ctx, key).Err()
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, key)
+			pipe.SRem(ctx, userSessionsKey(session.User.Id), hashSessionId(id))
+			return nil
+		})
+		return err
+	}
+
+	var err error
+	for range deleteSessionAttempts {
+		err = r.client.Watch(ctx, deleteSession, key)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return err
+}
+
+func (r *RedisSessionStore) DeleteUserSessions(ctx context.Context, userId string) error {
+	userKey := userSessionsKey(userId)
+	hashedIds, err := r.client.SMembers(ctx, userKey).Result()
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(hashedIds)+1)
+	for _, hashedId := range hashedIds {
+		keys = append(keys, sessionKey(hashedId))
+	}
+	keys = append(keys, userKey)
+	return r.client.Del(ctx, keys...).Err()
+}
+
+// MemorySessionStore keeps sessions in process, for running without Redis.
+// Sessions are lost on restart and not shared between instances.
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]memorySession
+}
+
+type memorySession struct {
+	session   Session
+	expiresAt time.Time
+}
+
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions: make(map[string]memorySession),
+	}
+}
+
+func (m *MemorySessionStore) CreateSession(_ context.Context, session *Session) (string, error) {
+	id, err := newSessionId()
+	if err != nil {
+		return "", err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sessions[hashSessionId(id)] = memorySession{session: *session, expiresAt: time.Now().Add(sessionIdleTTL)}
+	return id, nil
+}
+
+func (m *MemorySessionStore) GetSession(_ context.Context, id string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := hashSessionId(id)
+	stored, ok := m.sessions[key]
+	if !ok {
+		return nil, nil
+	}
+	if time.Now().After(stored.expiresAt) || stored.session.isExpired() {
+		delete(m.sessions, key)
+		return nil, nil
+	}
+	stored.expiresAt = time.Now().Add(sessionIdleTTL)
+	m.sessions[key] = stored
+	session := stored.session
+	return &session, nil
+}
+
+func (m *MemorySessionStore) UpdateSession(_ context.Context, id string, session *Session) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := hashSessionId(id)
+	if _, ok := m.sessions[key]; !ok {
+		return false, nil
+	}
+	m.sessions[key] = memorySession{session: *session, expiresAt: time.Now().Add(sessionIdleTTL)}
+	return true, nil
+}
+
+func (m *MemorySessionStore) DeleteSession(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, hashSessionId(id))
+	return nil
+}
+
+func (m *MemorySessionStore) DeleteUserSessions(_ context.Context, userId string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, stored := range m.sessions {
+		if stored.session.User.Id == userId {
+			delete(m.sessions, key)
+		}
+	}
+	return nil
+}
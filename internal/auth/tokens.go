@@ -0,0 +1,261 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/thebenkogan/ufc/internal/util/api"
+	"github.com/thebenkogan/ufc/internal/util/logs"
+)
+
+// Scope is what a personal access token may be used for
+type Scope string
+
+const (
+	ScopePicksRead  Scope = "picks:read"
+	ScopePicksWrite Scope = "picks:write"
+)
+
+var scopes = []Scope{ScopePicksRead, ScopePicksWrite}
+
+// prefix of every personal access token, so leaked tokens are easy to recognize
+const accessTokenPrefix = "ufcp_"
+
+const (
+	maxAccessTokenNameLength = 64
+	// tokens can be made to last at most a year, or never expire
+	maxAccessTokenExpiryDays = 365
+)
+
+// AccessToken is a personal access token for scripts and bots, sent as "Authorization: Bearer <token>"
+type AccessToken struct {
+	Id     int    `db:"id" json:"id"`
+	UserId string `db:"user_id" json:"-"`
+	Name   string `db:"name" json:"name"`
+	// only the hash of the token is stored, the token itself is shown once when created
+	TokenHash  string     `db:"token_hash" json:"-"`
+	Scopes     []Scope    `db:"scopes" json:"scopes"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+	LastUsedAt *time.Time `db:"last_used_at" json:"last_used_at"`
+	// nil if the token never expires
+	ExpiresAt *time.Time `db:"expires_at" json:"expires_at"`
+}
+
+type AccessTokenRepository interface {
+	CreateToken(ctx context.Context, token *AccessToken) error
+	ListTokens(ctx context.Context, userId string) ([]*AccessToken, error)
+	// DeleteToken revokes a token of the user, returning false if they have no such token
+	DeleteToken(ctx context.Context, userId string, id int) (bool, error)
+	// UseToken returns the unexpired token with the hash and records that it was used, or nil if there is none
+	UseToken(ctx context.Context, tokenHash string) (*AccessToken, error)
+}
+
+type PostgresAccessTokens struct {
+	client *pgxpool.Pool
+}
+
+func NewPostgresAccessTokens(client *pgxpool.Pool) *PostgresAccessTokens {
+	return &PostgresAccessTokens{
+		client: client,
+	}
+}
+
+func (p *PostgresAccessTokens) CreateToken(ctx context.Context, token *AccessToken) error {
+	return p.client.QueryRow(ctx, "INSERT INTO access_tokens (user_id, name, token_hash, scopes, expires_at) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at", token.UserId, token.Name, token.TokenHash, token.Scopes, token.ExpiresAt).Scan(&token.Id, &token.CreatedAt)
+}
+
+func (p *PostgresAccessTokens) ListTokens(ctx context.Context, userId string) ([]*AccessToken, error) {
+	rows, _ := p.client.Query(ctx, "SELECT * FROM access_tokens WHERE user_id = $1 ORDER BY id", userId)
+	return pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[AccessToken])
+}
+
+func (p *PostgresAccessTokens) DeleteToken(ctx context.Context, userId string, id int) (bool, error) {
+	tag, err := p.client.Exec(ctx, "DELETE FROM access_tokens WHERE user_id = $1 AND id = $2", userId, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (p *PostgresAccessTokens) UseToken(ctx context.Context, tokenHash string) (*AccessToken, error) {
+	rows, _ := p.client.Query(ctx, "UPDATE access_tokens SET last_used_at = CURRENT_TIMESTAMP WHERE token_hash = $1 AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP) RETURNING *", tokenHash)
+	tokens, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[AccessToken])
+	if err != nil || len(tokens) == 0 {
+		return nil, err
+	}
+	return tokens[0], nil
+}
+
+func hashAccessToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Returns the token in the Authorization header, if the request has one
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+const scopeKey authKey = "scope"
+
+// WithScope lets personal access tokens with the scope use the handler.
+// It must wrap the middleware that authenticates the request, tokens are rejected by routes without a scope.
+func WithScope(scope Scope, h api.Handler) api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		ctx = context.WithValue(ctx, scopeKey, scope)
+		return h(ctx, w, r.WithContext(ctx))
+	}
+}
+
+func requiredScope(ctx context.Context) (Scope, bool) {
+	scope, ok := ctx.Value(scopeKey).(Scope)
+	return scope, ok
+}
+
+// authenticateToken runs the handler as the owner of the personal access token,
+// if the token has the scope the route requires
+func (a *Registry) authenticateToken(ctx context.Context, w http.ResponseWriter, r *http.Request, raw string, h api.Handler) error {
+	token, err := a.tokens.UseToken(ctx, hashAccessToken(raw))
+	if err != nil {
+		return fmt.Errorf("failed to get access token: %w", err)
+	}
+	if token == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil
+	}
+
+	scope, ok := requiredScope(ctx)
+	if !ok || !slices.Contains(token.Scopes, scope) {
+		logs.Logger(ctx).Warn("access token missing scope", "token ID", token.Id, "scope", scope)
+		api.Encode(w, http.StatusForbidden, http.StatusText(http.StatusForbidden))
+		return nil
+	}
+
+	// tokens only carry the user's ID, routes that need more do not accept tokens
+	ctx = WithUser(ctx, &User{Id: token.UserId})
+	return h(ctx, w, r.WithContext(ctx))
+}
+
+type PostAccessTokenRequest struct {
+	Name   string  `json:"name"`
+	Scopes []Scope `json:"scopes"`
+	// 0 for a token that never expires
+	ExpiresInDays int `json:"expires_in_days"`
+}
+
+type PostAccessTokenResponse struct {
+	*AccessToken
+	// the token is only ever returned here
+	Token string `json:"token"`
+}
+
+func validateAccessToken(req *PostAccessTokenRequest) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > maxAccessTokenNameLength {
+		return fmt.Errorf("name must be between 1 and %d characters", maxAccessTokenNameLength)
+	}
+	if len(req.Scopes) == 0 {
+		return fmt.Errorf("at least one scope is required")
+	}
+	for _, s := range req.Scopes {
+		if !slices.Contains(scopes, s) {
+			return fmt.Errorf("unknown scope: %q", s)
+		}
+	}
+	if req.ExpiresInDays < 0 || req.ExpiresInDays > maxAccessTokenExpiryDays {
+		return fmt.Errorf("expires_in_days must be between 0 and %d", maxAccessTokenExpiryDays)
+	}
+	return nil
+}
+
+func HandlePostAccessToken(tokens AccessTokenRepository) api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		user := GetUser(ctx)
+		if user == nil {
+			return fmt.Errorf("no user in context")
+		}
+
+		var req PostAccessTokenRequest
+		api.Decode(r, &req)
+		if err := validateAccessToken(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil
+		}
+
+		random, err := randString(32)
+		if err != nil {
+			return err
+		}
+		raw := accessTokenPrefix + random
+		token := &AccessToken{UserId: user.Id, Name: req.Name, TokenHash: hashAccessToken(raw), Scopes: req.Scopes}
+		if req.ExpiresInDays > 0 {
+			expiresAt := time.Now().Add(time.Duration(req.ExpiresInDays) * 24 * time.Hour)
+			token.ExpiresAt = &expiresAt
+		}
+		if err := tokens.CreateToken(ctx, token); err != nil {
+			return fmt.Errorf("error creating access token: %w", err)
+		}
+		logs.Logger(ctx).Info("created access token", "token ID", token.Id, "scopes", token.Scopes)
+
+		api.Encode(w, http.StatusCreated, PostAccessTokenResponse{AccessToken: token, Token: raw})
+		return nil
+	}
+}
+
+func HandleListAccessTokens(tokens AccessTokenRepository) api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		user := GetUser(ctx)
+		if user == nil {
+			return fmt.Errorf("no user in context")
+		}
+
+		list, err := tokens.ListTokens(ctx, user.Id)
+		if err != nil {
+			return fmt.Errorf("error listing access tokens: %w", err)
+		}
+
+		api.Encode(w, http.StatusOK, list)
+		return nil
+	}
+}
+
+func HandleDeleteAccessToken(tokens AccessTokenRepository) api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		user := GetUser(ctx)
+		if user == nil {
+			return fmt.Errorf("no user in context")
+		}
+
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "access token not found", http.StatusNotFound)
+			return nil
+		}
+
+		deleted, err := tokens.DeleteToken(ctx, user.Id, id)
+		if err != nil {
+			return fmt.Errorf("error deleting access token: %w", err)
+		}
+		if !deleted {
+			http.Error(w, "access token not found", http.StatusNotFound)
+			return nil
+		}
+		logs.Logger(ctx).Info("revoked access token", "token ID", id)
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+}
@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thebenkogan/ufc/internal/util/api"
+)
+
+type testAccessTokens struct {
+	tokens []*AccessToken
+}
+
+func (t *testAccessTokens) CreateToken(_ context.Context, token *AccessToken) error {
+	token.Id = len(t.tokens) + 1
+	token.CreatedAt = time.Now()
+	t.tokens = append(t.tokens, token)
+	return nil
+}
+
+func (t *testAccessTokens) ListTokens(_ context.Context, userId string) ([]*AccessToken, error) {
+	list := make([]*AccessToken, 0)
+	for _, token := range t.tokens {
+		if token.UserId == userId {
+			list = append(list, token)
+		}
+	}
+	return list, nil
+}
+
+func (t *testAccessTokens) DeleteToken(_ context.Context, userId string, id int) (bool, error) {
+	for i, token := range t.tokens {
+		if token.UserId == userId && token.Id == id {
+			t.tokens = append(t.tokens[:i], t.tokens[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (t *testAccessTokens) UseToken(_ context.Context, tokenHash string) (*AccessToken, error) {
+	for _, token := range t.tokens {
+		if token.TokenHash == tokenHash && (token.ExpiresAt == nil || token.ExpiresAt.After(time.Now())) {
+			now := time.Now()
+			token.LastUsedAt = &now
+			return token, nil
+		}
+	}
+	return nil, nil
+}
+
+func TestAccessTokens(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemorySessionStore()
+	tokens := &testAccessTokens{}
+	registry := NewRegistry(store, nil, tokens)
+
+	now := time.Now()
+	sessionId, err := store.CreateSession(ctx, &Session{User: User{Id: "1"}, CreatedAt: now, RefreshedAt: now})
+	require.NoError(t, err)
+	session := &http.Cookie{Name: sessionCookie, Value: sessionId}
+
+	// echoes the ID of the user the request is made as
+	whoami := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		_, _ = w.Write([]byte(GetUser(ctx).Id))
+		return nil
+	}
+	handle := func(h api.Handler) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			require.NoError(t, h(r.Context(), w, r))
+		}
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /picks", handle(WithScope(ScopePicksRead, registry.Middleware(whoami))))
+	mux.Handle("POST /picks", handle(WithScope(ScopePicksWrite, registry.Middleware(whoami))))
+	mux.Handle("GET /me/notifications", handle(registry.Middleware(whoami)))
+	mux.Handle("POST /me/tokens", handle(registry.Middleware(HandlePostAccessToken(tokens))))
+	mux.Handle("DELETE /me/tokens/{id}", handle(registry.Middleware(HandleDeleteAccessToken(tokens))))
+
+	serve := func(method, target, body, bearer string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		if bearer == "" {
+			r.AddCookie(session)
+		} else {
+			r.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve(http.MethodPost, "/me/tokens", `{"name": "bot", "scopes": ["picks:admin"]}`, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = serve(http.MethodPost, "/me/tokens", `{"name": " ", "scopes": ["picks:read"]}`, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = serve(http.MethodPost, "/me/tokens", `{"name": "bot", "scopes": ["picks:read"], "expires_in_days": -1}`, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	// long enough to overflow the expiry time
+	w = serve(http.MethodPost, "/me/tokens", `{"name": "bot", "scopes": ["picks:read"], "expires_in_days": 200000}`, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = serve(http.MethodPost, "/me/tokens", `{"name": "bot", "scopes": ["picks:read"], "expires_in_days": 366}`, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, tokens.tokens)
+
+	w = serve(http.MethodPost, "/me/tokens", `{"name": "bot", "scopes": ["picks:read"], "expires_in_days": 30}`, "")
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created PostAccessTokenResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+	assert.True(t, strings.HasPrefix(created.Token, accessTokenPrefix))
+	require.NotNil(t, created.ExpiresAt)
+	assert.WithinDuration(t, now.Add(30*24*time.Hour), *created.ExpiresAt, time.Minute)
+	require.Len(t, tokens.tokens, 1)
+	assert.NotContains(t, tokens.tokens[0].TokenHash, created.Token, "only the hash should be stored")
+
+	w = serve(http.MethodGet, "/picks", "", created.Token)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Body.String())
+	assert.NotNil(t, tokens.tokens[0].LastUsedAt)
+
+	// the token lacks the scope
+	w = serve(http.MethodPost, "/picks", "", created.Token)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	// the route does not accept tokens
+	w = serve(http.MethodGet, "/me/notifications", "", created.Token)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	// tokens cannot create more tokens
+	w = serve(http.MethodPost, "/me/tokens", `{"name": "bot", "scopes": ["picks:write"]}`, created.Token)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = serve(http.MethodGet, "/picks", "", accessTokenPrefix+"unknown")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// expired tokens are rejected
+	expired := now.Add(-time.Minute)
+	tokens.tokens[0].ExpiresAt = &expired
+	w = serve(http.MethodGet, "/picks", "", created.Token)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	tokens.tokens[0].ExpiresAt = nil
+
+	w = serve(http.MethodDelete, "/me/tokens/2", "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = serve(http.MethodDelete, "/me/tokens/1", "", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = serve(http.MethodGet, "/picks", "", created.Token)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
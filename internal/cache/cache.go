@@ -2,66 +2,184 @@ package cache
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"github.com/thebenkogan/ufc/internal/model"
+	"github.com/thebenkogan/ufc/internal/util/logs"
 )
 
+// CachedEvent is a cached event with its freshness.
+// Entries outlive their freshness so stale data can be served while it is refreshed.
+type CachedEvent struct {
+	Event     *model.Event `json:"event"`
+	FetchedAt time.Time    `json:"fetched_at"`
+	// When the event should be refreshed, zero if it never goes stale
+	FreshUntil time.Time `json:"fresh_until"`
+}
+
+func (c *CachedEvent) IsStale() bool {
+	return c.IsStaleWithin(0)
+}
+
+// Reports whether the entry will be stale d from now
+func (c *CachedEvent) IsStaleWithin(d time.Duration) bool {
+	return !c.FreshUntil.IsZero() && time.Now().Add(d).After(c.FreshUntil)
+}
+
 type EventCacheRepository interface {
-	GetEvent(ctx context.Context, id string) (*model.Event, error)
-	SetEvent(ctx context.Context, id string, event *model.Event, ttl time.Duration) error
-	GetSchedule(ctx context.Context) ([]*model.EventInfo, error)
-	SetSchedule(ctx context.Context, events []*model.EventInfo, ttl time.Duration) error
+	GetEvent(ctx context.Context, id string) (*CachedEvent, error)
+	// GetEvents gets many events in one round trip, the result only contains the IDs that were cached
+	GetEvents(ctx context.Context, ids []string) (map[string]*CachedEvent, error)
+	// SetEvent stores the entry until it hard expires after ttl, 0 meaning never
+	SetEvent(ctx context.Context, id string, entry *CachedEvent, ttl time.Duration) error
+	GetSchedule(ctx context.Context, league string) ([]*model.EventInfo, error)
+	SetSchedule(ctx context.Context, league string, events []*model.EventInfo, ttl time.Duration) error
+	// LockEvent tries to take a lock on refreshing the event that is shared by all instances.
+	// If acquired, release must be called once the event has been stored.
+	// The lock expires after ttl in case the holder never releases it.
+	LockEvent(ctx context.Context, id string, ttl time.Duration) (release func(ctx context.Context) error, acquired bool, err error)
+	// ListEvents returns the IDs of all cached events with their remaining ttl
+	ListEvents(ctx context.Context) ([]EventKey, error)
+	DeleteEvent(ctx context.Context, id string) error
+	DeleteSchedule(ctx context.Context, league string) error
+}
+
+type EventKey struct {
+	Id string
+	// remaining time until the entry hard expires, 0 if it never does
+	TTL time.Duration
 }
 
 type RedisEventCache struct {
 	client *redis.Client
+	codec  Codec
 }
 
-func NewRedisEventCache(client *redis.Client) *RedisEventCache {
+func NewRedisEventCache(client *redis.Client, codec Codec) *RedisEventCache {
 	return &RedisEventCache{
 		client: client,
+		codec:  codec,
 	}
 }
 
+func versionedKey(key string) string {
+	return fmt.Sprintf("v%d:%s", schemaVersion, key)
+}
+
 func (_ *RedisEventCache) key(id string) string {
-	return "events#" + id
+	return versionedKey("events#" + id)
 }
 
-func (r *RedisEventCache) GetEvent(ctx context.Context, id string) (*model.Event, error) {
-	eventJSON, err := r.client.Get(ctx, r.key(id)).Result()
+func (r *RedisEventCache) GetEvent(ctx context.Context, id string) (*CachedEvent, error) {
+	data, err := r.client.Get(ctx, r.key(id)).Bytes()
 	if err != nil {
 		if err == redis.Nil {
 			return nil, nil
 		}
 		return nil, err
 	}
-	var event model.Event
-	if err := json.Unmarshal([]byte(eventJSON), &event); err != nil {
+	return r.decodeEvent(ctx, id, data), nil
+}
+
+func (r *RedisEventCache) GetEvents(ctx context.Context, ids []string) (map[string]*CachedEvent, error) {
+	entries := make(map[string]*CachedEvent, len(ids))
+	if len(ids) == 0 {
+		return entries, nil
+	}
+
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, r.key(id))
+	}
+	values, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
 		return nil, err
 	}
-	return &event, nil
+
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		if entry := r.decodeEvent(ctx, ids[i], []byte(data)); entry != nil {
+			entries[ids[i]] = entry
+		}
+	}
+	return entries, nil
 }
 
-func (r *RedisEventCache) SetEvent(ctx context.Context, id string, event *model.Event, ttl time.Duration) error {
-	eventJSON, err := json.Marshal(event)
+// Decodes a cached entry, returning nil as a miss if it was written with another
+// schema version or cannot be decoded, so one bad entry never fails a read
+func (_ *RedisEventCache) decodeEvent(ctx context.Context, id string, data []byte) *CachedEvent {
+	var entry CachedEvent
+	ok, err := decodeEnvelope(data, &entry)
+	if err != nil {
+		logs.Logger(ctx).Warn("failed to decode cached event, treating it as a miss", "event ID", id, "error", err)
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+	return &entry
+}
+
+func (r *RedisEventCache) SetEvent(ctx context.Context, id string, entry *CachedEvent, ttl time.Duration) error {
+	data, err := encodeEnvelope(r.codec, entry)
 	if err != nil {
 		return err
 	}
-	if err := r.client.Set(ctx, r.key(id), string(eventJSON), ttl).Err(); err != nil {
+	if err := r.client.Set(ctx, r.key(id), data, ttl).Err(); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (_ *RedisEventCache) upcomingEventsKey() string {
-	return "upcoming_events"
+func (r *RedisEventCache) ListEvents(ctx context.Context) ([]EventKey, error) {
+	keys := make([]string, 0)
+	iter := r.client.Scan(ctx, 0, r.key("*"), 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+
+	pipe := r.client.Pipeline()
+	ttls := make([]*redis.DurationCmd, 0, len(keys))
+	for _, key := range keys {
+		ttls = append(ttls, pipe.TTL(ctx, key))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	prefix := r.key("")
+	eventKeys := make([]EventKey, 0, len(keys))
+	for i, key := range keys {
+		ttl := ttls[i].Val()
+		if ttl == -2 {
+			// expired since the scan
+			continue
+		}
+		eventKeys = append(eventKeys, EventKey{Id: strings.TrimPrefix(key, prefix), TTL: max(ttl, 0)})
+	}
+	return eventKeys, nil
+}
+
+func (r *RedisEventCache) DeleteEvent(ctx context.Context, id string) error {
+	return r.client.Del(ctx, r.key(id)).Err()
 }
 
-func (r *RedisEventCache) GetSchedule(ctx context.Context) ([]*model.EventInfo, error) {
-	eventJSON, err := r.client.Get(ctx, r.upcomingEventsKey()).Result()
+func (_ *RedisEventCache) upcomingEventsKey(league string) string {
+	return versionedKey("upcoming_events#" + league)
+}
+
+func (r *RedisEventCache) GetSchedule(ctx context.Context, league string) ([]*model.EventInfo, error) {
+	data, err := r.client.Get(ctx, r.upcomingEventsKey(league)).Bytes()
 	if err != nil {
 		if err == redis.Nil {
 			return nil, nil
@@ -69,19 +187,79 @@ func (r *RedisEventCache) GetSchedule(ctx context.Context) ([]*model.EventInfo,
 		return nil, err
 	}
 	var events []*model.EventInfo
-	if err := json.Unmarshal([]byte(eventJSON), &events); err != nil {
+	if ok, err := decodeEnvelope(data, &events); !ok || err != nil {
 		return nil, err
 	}
 	return events, nil
 }
 
-func (r *RedisEventCache) SetSchedule(ctx context.Context, events []*model.EventInfo, ttl time.Duration) error {
-	eventJSON, err := json.Marshal(events)
+func (r *RedisEventCache) SetSchedule(ctx context.Context, league string, events []*model.EventInfo, ttl time.Duration) error {
+	data, err := encodeEnvelope(r.codec, events)
 	if err != nil {
 		return err
 	}
-	if err := r.client.Set(ctx, r.upcomingEventsKey(), string(eventJSON), ttl).Err(); err != nil {
+	if err := r.client.Set(ctx, r.upcomingEventsKey(league), data, ttl).Err(); err != nil {
 		return err
 	}
 	return nil
 }
+
+func (_ *RedisEventCache) lockKey(id string) string {
+	return "lock#events#" + id
+}
+
+// only deletes the lock if it is still held by the given token
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+func (r *RedisEventCache) LockEvent(ctx context.Context, id string, ttl time.Duration) (func(ctx context.Context) error, bool, error) {
+	token := uuid.New().String()
+	acquired, err := r.client.SetNX(ctx, r.lockKey(id), token, ttl).Result()
+	if err != nil || !acquired {
+		return nil, false, err
+	}
+	release := func(ctx context.Context) error {
+		return unlockScript.Run(ctx, r.client, []string{r.lockKey(id)}, token).Err()
+	}
+	return release, true, nil
+}
+
+func (r *RedisEventCache) DeleteSchedule(ctx context.Context, league string) error {
+	return r.client.Del(ctx, r.upcomingEventsKey(league)).Err()
+}
+
+const invalidationChannel = "cache_invalidations"
+
+func (r *RedisEventCache) PublishInvalidation(ctx context.Context, key string) error {
+	return r.client.Publish(ctx, invalidationChannel, key).Err()
+}
+
+func (r *RedisEventCache) SubscribeInvalidations(ctx context.Context) <-chan string {
+	keys := make(chan string)
+	pubsub := r.client.Subscribe(ctx, invalidationChannel)
+	go func() {
+		defer close(keys)
+		defer pubsub.Close()
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				select {
+				case keys <- msg.Payload:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return keys
+}
@@ -0,0 +1,178 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thebenkogan/ufc/internal/model"
+)
+
+func testEntry(id string) *CachedEvent {
+	return &CachedEvent{Event: &model.Event{Id: id}, FetchedAt: time.Now()}
+}
+
+func TestMemoryEventCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should evict the least recently used event", func(t *testing.T) {
+		c := NewMemoryEventCache(2)
+		_ = c.SetEvent(ctx, "1", testEntry("1"), 0)
+		_ = c.SetEvent(ctx, "2", testEntry("2"), 0)
+		_, _ = c.GetEvent(ctx, "1")
+		_ = c.SetEvent(ctx, "3", testEntry("3"), 0)
+
+		got, _ := c.GetEvent(ctx, "2")
+		assert.Nil(t, got)
+		for _, id := range []string{"1", "3"} {
+			got, _ := c.GetEvent(ctx, id)
+			assert.Equal(t, id, got.Event.Id)
+		}
+	})
+
+	t.Run("should expire events and schedules after their ttl", func(t *testing.T) {
+		c := NewMemoryEventCache(2)
+		_ = c.SetEvent(ctx, "1", testEntry("1"), 20*time.Millisecond)
+		_ = c.SetSchedule(ctx, model.LeagueUFC, []*model.EventInfo{{Id: "1"}}, 20*time.Millisecond)
+
+		got, _ := c.GetEvent(ctx, "1")
+		assert.NotNil(t, got)
+		time.Sleep(30 * time.Millisecond)
+		got, _ = c.GetEvent(ctx, "1")
+		assert.Nil(t, got)
+		schedule, _ := c.GetSchedule(ctx, model.LeagueUFC)
+		assert.Nil(t, schedule)
+	})
+
+	t.Run("should list and delete events", func(t *testing.T) {
+		c := NewMemoryEventCache(2)
+		_ = c.SetEvent(ctx, "1", testEntry("1"), 0)
+		_ = c.SetEvent(ctx, "2", testEntry("2"), time.Minute)
+
+		keys, _ := c.ListEvents(ctx)
+		assert.Len(t, keys, 2)
+		assert.Equal(t, "2", keys[0].Id)
+		assert.InDelta(t, time.Minute.Seconds(), keys[0].TTL.Seconds(), 1)
+		assert.Equal(t, EventKey{Id: "1"}, keys[1])
+
+		_ = c.DeleteEvent(ctx, "2")
+		keys, _ = c.ListEvents(ctx)
+		assert.Equal(t, []EventKey{{Id: "1"}}, keys)
+	})
+
+	t.Run("should only hand out a lock once until released", func(t *testing.T) {
+		c := NewMemoryEventCache(2)
+		release, acquired, _ := c.LockEvent(ctx, "1", time.Minute)
+		assert.True(t, acquired)
+		_, acquired, _ = c.LockEvent(ctx, "1", time.Minute)
+		assert.False(t, acquired)
+		_ = release(ctx)
+		_, acquired, _ = c.LockEvent(ctx, "1", time.Minute)
+		assert.True(t, acquired)
+	})
+}
+
+func TestEnvelope(t *testing.T) {
+	entry := &CachedEvent{
+		Event: &model.Event{
+			Id:        "1",
+			League:    model.LeagueUFC,
+			StartTime: "LIVE",
+			Fights: []model.Fight{
+				{Fighters: []string{"A", "B"}, Winner: "A", Odds: map[string]int{"A": 150, "B": -180}},
+				{Fighters: []string{"C", "D"}},
+			},
+		},
+		FetchedAt:  time.Now().UTC().Truncate(time.Millisecond),
+		FreshUntil: time.Now().Add(time.Minute).UTC().Truncate(time.Millisecond),
+	}
+
+	for _, codec := range codecs {
+		t.Run(codec.Name(), func(t *testing.T) {
+			data, err := encodeEnvelope(codec, entry)
+			assert.NoError(t, err)
+
+			var got CachedEvent
+			ok, err := decodeEnvelope(data, &got)
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, entry.Event, got.Event)
+			assert.True(t, entry.FetchedAt.Equal(got.FetchedAt))
+			assert.True(t, entry.FreshUntil.Equal(got.FreshUntil))
+		})
+	}
+
+	t.Run("should skip values from another schema version", func(t *testing.T) {
+		data, _ := encodeEnvelope(JSONCodec{}, entry)
+		data[0] = schemaVersion + 1
+		ok, err := decodeEnvelope(data, &CachedEvent{})
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+}
+
+// delivers invalidations to every subscriber, like Redis pub/sub
+type testInvalidator struct {
+	mu          sync.Mutex
+	subscribers []chan string
+}
+
+func (i *testInvalidator) numSubscribers() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return len(i.subscribers)
+}
+
+func (i *testInvalidator) PublishInvalidation(_ context.Context, key string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, s := range i.subscribers {
+		s <- key
+	}
+	return nil
+}
+
+func (i *testInvalidator) SubscribeInvalidations(ctx context.Context) <-chan string {
+	keys := make(chan string, 10)
+	i.mu.Lock()
+	i.subscribers = append(i.subscribers, keys)
+	i.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		close(keys)
+	}()
+	return keys
+}
+
+func TestTieredEventCache(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	l2 := NewMemoryEventCache(10)
+	invalidator := &testInvalidator{}
+	a := NewTieredEventCache(NewMemoryEventCache(10), l2, time.Minute, invalidator)
+	b := NewTieredEventCache(NewMemoryEventCache(10), l2, time.Minute, invalidator)
+	go a.Run(ctx)
+	go b.Run(ctx)
+	assert.Eventually(t, func() bool { return invalidator.numSubscribers() == 2 }, time.Second, time.Millisecond)
+
+	_ = a.SetEvent(ctx, "1", testEntry("1"), 0)
+	got, _ := b.GetEvent(ctx, "1")
+	assert.Equal(t, "1", got.Event.Id)
+
+	// b now has "1" in its L1, a's write must replace it
+	updated := testEntry("1")
+	updated.Event.Name = "updated"
+	_ = a.SetEvent(ctx, "1", updated, 0)
+
+	assert.Eventually(t, func() bool {
+		got, _ := b.GetEvent(ctx, "1")
+		return got.Event.Name == "updated"
+	}, time.Second, 10*time.Millisecond)
+
+	// a does not drop its own L1 entry
+	inL1, _ := a.l1.GetEvent(ctx, "1")
+	assert.Equal(t, updated, inL1)
+}
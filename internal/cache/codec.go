@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec serializes values stored in the cache
+type Codec interface {
+	// ID identifies the codec in stored envelopes, it must never change
+	ID() byte
+	Name() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+type JSONCodec struct{}
+
+func (JSONCodec) ID() byte     { return 1 }
+func (JSONCodec) Name() string { return "json" }
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// GzipJSONCodec is JSON compressed with gzip, trading CPU for memory on large cards
+type GzipJSONCodec struct{}
+
+func (GzipJSONCodec) ID() byte     { return 2 }
+func (GzipJSONCodec) Name() string { return "gzip-json" }
+
+func (GzipJSONCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(v); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GzipJSONCodec) Unmarshal(data []byte, v any) error {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	body, err := io.ReadAll(zr)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// MsgpackCodec is a compact binary encoding that reuses the json struct tags
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) ID() byte     { return 3 }
+func (MsgpackCodec) Name() string { return "msgpack" }
+
+func (MsgpackCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (MsgpackCodec) Unmarshal(data []byte, v any) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+var codecs = []Codec{JSONCodec{}, GzipJSONCodec{}, MsgpackCodec{}}
+
+// Returns the codec with the given name, defaulting to JSON for an empty name
+func CodecByName(name string) (Codec, error) {
+	if name == "" {
+		return JSONCodec{}, nil
+	}
+	for _, c := range codecs {
+		if c.Name() == name {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown cache codec: %s", name)
+}
+
+func codecByID(id byte) (Codec, bool) {
+	for _, c := range codecs {
+		if c.ID() == id {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// schemaVersion must be bumped whenever the shape of cached values changes.
+// It is part of every key, so a deploy never reads values written by another version.
+const schemaVersion = 2
+
+// Cached values are stored in an envelope of the schema version and the codec ID
+// followed by the encoded value, so the codec can change without flushing the cache.
+func encodeEnvelope(codec Codec, v any) ([]byte, error) {
+	payload, err := codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{schemaVersion, codec.ID()}, payload...), nil
+}
+
+// Decodes the envelope into v, returning false if it was written with another schema version
+func decodeEnvelope(data []byte, v any) (bool, error) {
+	if len(data) < 2 || data[0] != schemaVersion {
+		return false, nil
+	}
+	codec, ok := codecByID(data[1])
+	if !ok {
+		return false, fmt.Errorf("unknown cache codec ID: %d", data[1])
+	}
+	if err := codec.Unmarshal(data[2:], v); err != nil {
+		return false, err
+	}
+	return true, nil
+}
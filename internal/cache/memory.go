@@ -0,0 +1,192 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thebenkogan/ufc/internal/model"
+)
+
+// MemoryEventCache is an in-process EventCacheRepository.
+// Once it holds capacity events, the least recently used event is evicted.
+// Locks only coordinate within this process.
+type MemoryEventCache struct {
+	mu        sync.Mutex
+	capacity  int
+	events    map[string]*list.Element
+	lru       *list.List // front is the most recently used
+	schedules map[string]memorySchedule
+	locks     map[string]memoryLock
+}
+
+type memoryEvent struct {
+	id        string
+	entry     *CachedEvent
+	expiresAt time.Time // zero if it never expires
+}
+
+type memorySchedule struct {
+	events    []*model.EventInfo
+	expiresAt time.Time
+}
+
+type memoryLock struct {
+	token     string
+	expiresAt time.Time
+}
+
+func NewMemoryEventCache(capacity int) *MemoryEventCache {
+	return &MemoryEventCache{
+		capacity:  capacity,
+		events:    make(map[string]*list.Element),
+		lru:       list.New(),
+		schedules: make(map[string]memorySchedule),
+		locks:     make(map[string]memoryLock),
+	}
+}
+
+func expiresAt(ttl time.Duration) time.Time {
+	if ttl == 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+func isExpired(expiresAt time.Time) bool {
+	return !expiresAt.IsZero() && time.Now().After(expiresAt)
+}
+
+func (m *MemoryEventCache) GetEvent(_ context.Context, id string) (*CachedEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	elem, ok := m.events[id]
+	if !ok {
+		return nil, nil
+	}
+	item := elem.Value.(*memoryEvent)
+	if isExpired(item.expiresAt) {
+		m.removeElement(elem)
+		return nil, nil
+	}
+	m.lru.MoveToFront(elem)
+	return item.entry, nil
+}
+
+func (m *MemoryEventCache) GetEvents(ctx context.Context, ids []string) (map[string]*CachedEvent, error) {
+	entries := make(map[string]*CachedEvent, len(ids))
+	for _, id := range ids {
+		if entry, _ := m.GetEvent(ctx, id); entry != nil {
+			entries[id] = entry
+		}
+	}
+	return entries, nil
+}
+
+func (m *MemoryEventCache) SetEvent(_ context.Context, id string, entry *CachedEvent, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if elem, ok := m.events[id]; ok {
+		item := elem.Value.(*memoryEvent)
+		item.entry = entry
+		item.expiresAt = expiresAt(ttl)
+		m.lru.MoveToFront(elem)
+		return nil
+	}
+
+	m.events[id] = m.lru.PushFront(&memoryEvent{id: id, entry: entry, expiresAt: expiresAt(ttl)})
+	for m.lru.Len() > m.capacity {
+		m.removeElement(m.lru.Back())
+	}
+	return nil
+}
+
+func (m *MemoryEventCache) ListEvents(_ context.Context) ([]EventKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]EventKey, 0, m.lru.Len())
+	for elem := m.lru.Front(); elem != nil; elem = elem.Next() {
+		item := elem.Value.(*memoryEvent)
+		if isExpired(item.expiresAt) {
+			continue
+		}
+		key := EventKey{Id: item.id}
+		if !item.expiresAt.IsZero() {
+			key.TTL = time.Until(item.expiresAt)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (m *MemoryEventCache) DeleteEvent(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if elem, ok := m.events[id]; ok {
+		m.removeElement(elem)
+	}
+	return nil
+}
+
+func (m *MemoryEventCache) removeElement(elem *list.Element) {
+	m.lru.Remove(elem)
+	delete(m.events, elem.Value.(*memoryEvent).id)
+}
+
+func (m *MemoryEventCache) GetSchedule(_ context.Context, league string) ([]*model.EventInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	schedule, ok := m.schedules[league]
+	if !ok {
+		return nil, nil
+	}
+	if isExpired(schedule.expiresAt) {
+		delete(m.schedules, league)
+		return nil, nil
+	}
+	return schedule.events, nil
+}
+
+func (m *MemoryEventCache) SetSchedule(_ context.Context, league string, events []*model.EventInfo, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.schedules[league] = memorySchedule{events: events, expiresAt: expiresAt(ttl)}
+	return nil
+}
+
+func (m *MemoryEventCache) DeleteSchedule(_ context.Context, league string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.schedules, league)
+	return nil
+}
+
+func (m *MemoryEventCache) LockEvent(_ context.Context, id string, ttl time.Duration) (func(ctx context.Context) error, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if lock, ok := m.locks[id]; ok && !isExpired(lock.expiresAt) {
+		return nil, false, nil
+	}
+	token := uuid.New().String()
+	m.locks[id] = memoryLock{token: token, expiresAt: expiresAt(ttl)}
+
+	release := func(_ context.Context) error {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.locks[id].token == token {
+			delete(m.locks, id)
+		}
+		return nil
+	}
+	return release, true, nil
+}
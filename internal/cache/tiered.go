@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thebenkogan/ufc/internal/model"
+	"github.com/thebenkogan/ufc/internal/util/logs"
+)
+
+// Invalidator broadcasts cache writes so other instances can drop their local copies
+type Invalidator interface {
+	PublishInvalidation(ctx context.Context, key string) error
+	// SubscribeInvalidations returns the published keys until ctx is done
+	SubscribeInvalidations(ctx context.Context) <-chan string
+}
+
+// TieredEventCache keeps recently used entries in process (L1) in front of a shared cache (L2).
+// L1 entries live for at most l1TTL, and every write is broadcast so other
+// instances drop their L1 copy instead of serving it until it expires.
+type TieredEventCache struct {
+	l1          *MemoryEventCache
+	l2          EventCacheRepository
+	l1TTL       time.Duration
+	invalidator Invalidator
+	instanceId  string
+}
+
+func NewTieredEventCache(l1 *MemoryEventCache, l2 EventCacheRepository, l1TTL time.Duration, invalidator Invalidator) *TieredEventCache {
+	return &TieredEventCache{
+		l1:          l1,
+		l2:          l2,
+		l1TTL:       l1TTL,
+		invalidator: invalidator,
+		instanceId:  uuid.New().String(),
+	}
+}
+
+const (
+	eventInvalidationPrefix    = "events#"
+	scheduleInvalidationPrefix = "upcoming_events#"
+)
+
+// Run drops L1 entries written by other instances until ctx is done
+func (t *TieredEventCache) Run(ctx context.Context) {
+	for msg := range t.invalidator.SubscribeInvalidations(ctx) {
+		instanceId, key, ok := strings.Cut(msg, "|")
+		if !ok || instanceId == t.instanceId {
+			continue
+		}
+		if id, ok := strings.CutPrefix(key, eventInvalidationPrefix); ok {
+			_ = t.l1.DeleteEvent(ctx, id)
+		} else if league, ok := strings.CutPrefix(key, scheduleInvalidationPrefix); ok {
+			_ = t.l1.DeleteSchedule(ctx, league)
+		}
+	}
+}
+
+func (t *TieredEventCache) invalidate(ctx context.Context, key string) {
+	if err := t.invalidator.PublishInvalidation(ctx, t.instanceId+"|"+key); err != nil {
+		logs.Logger(ctx).Warn("failed to publish cache invalidation", "key", key, "error", err)
+	}
+}
+
+// the L1 entry must not outlive the L2 entry
+func (t *TieredEventCache) l1TTLFor(ttl time.Duration) time.Duration {
+	if ttl == 0 || ttl > t.l1TTL {
+		return t.l1TTL
+	}
+	return ttl
+}
+
+func (t *TieredEventCache) GetEvent(ctx context.Context, id string) (*CachedEvent, error) {
+	if entry, _ := t.l1.GetEvent(ctx, id); entry != nil {
+		return entry, nil
+	}
+	entry, err := t.l2.GetEvent(ctx, id)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	_ = t.l1.SetEvent(ctx, id, entry, t.l1TTL)
+	return entry, nil
+}
+
+func (t *TieredEventCache) GetEvents(ctx context.Context, ids []string) (map[string]*CachedEvent, error) {
+	entries, _ := t.l1.GetEvents(ctx, ids)
+	misses := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := entries[id]; !ok {
+			misses = append(misses, id)
+		}
+	}
+	if len(misses) == 0 {
+		return entries, nil
+	}
+
+	fromL2, err := t.l2.GetEvents(ctx, misses)
+	if err != nil {
+		return nil, err
+	}
+	for id, entry := range fromL2 {
+		_ = t.l1.SetEvent(ctx, id, entry, t.l1TTL)
+		entries[id] = entry
+	}
+	return entries, nil
+}
+
+func (t *TieredEventCache) SetEvent(ctx context.Context, id string, entry *CachedEvent, ttl time.Duration) error {
+	if err := t.l2.SetEvent(ctx, id, entry, ttl); err != nil {
+		return err
+	}
+	_ = t.l1.SetEvent(ctx, id, entry, t.l1TTLFor(ttl))
+	t.invalidate(ctx, eventInvalidationPrefix+id)
+	return nil
+}
+
+func (t *TieredEventCache) GetSchedule(ctx context.Context, league string) ([]*model.EventInfo, error) {
+	if events, _ := t.l1.GetSchedule(ctx, league); events != nil {
+		return events, nil
+	}
+	events, err := t.l2.GetSchedule(ctx, league)
+	if err != nil || events == nil {
+		return nil, err
+	}
+	_ = t.l1.SetSchedule(ctx, league, events, t.l1TTL)
+	return events, nil
+}
+
+func (t *TieredEventCache) SetSchedule(ctx context.Context, league string, events []*model.EventInfo, ttl time.Duration) error {
+	if err := t.l2.SetSchedule(ctx, league, events, ttl); err != nil {
+		return err
+	}
+	_ = t.l1.SetSchedule(ctx, league, events, t.l1TTLFor(ttl))
+	t.invalidate(ctx, scheduleInvalidationPrefix+league)
+	return nil
+}
+
+// locks must be shared by all instances, so they are taken in L2
+func (t *TieredEventCache) LockEvent(ctx context.Context, id string, ttl time.Duration) (func(ctx context.Context) error, bool, error) {
+	return t.l2.LockEvent(ctx, id, ttl)
+}
+
+// L2 holds every entry, L1 only a subset
+func (t *TieredEventCache) ListEvents(ctx context.Context) ([]EventKey, error) {
+	return t.l2.ListEvents(ctx)
+}
+
+func (t *TieredEventCache) DeleteEvent(ctx context.Context, id string) error {
+	if err := t.l2.DeleteEvent(ctx, id); err != nil {
+		return err
+	}
+	_ = t.l1.DeleteEvent(ctx, id)
+	t.invalidate(ctx, eventInvalidationPrefix+id)
+	return nil
+}
+
+func (t *TieredEventCache) DeleteSchedule(ctx context.Context, league string) error {
+	if err := t.l2.DeleteSchedule(ctx, league); err != nil {
+		return err
+	}
+	_ = t.l1.DeleteSchedule(ctx, league)
+	t.invalidate(ctx, scheduleInvalidationPrefix+league)
+	return nil
+}
@@ -0,0 +1,102 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/thebenkogan/ufc/internal/cache"
+	"github.com/thebenkogan/ufc/internal/util/api"
+	"github.com/thebenkogan/ufc/internal/util/logs"
+)
+
+type CachedEventKey struct {
+	Id string `json:"id"`
+	// seconds until the entry expires, omitted if it never does
+	TTL int `json:"ttl,omitempty"`
+}
+
+func HandleListCachedEvents(eventCache cache.EventCacheRepository) api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		keys, err := eventCache.ListEvents(ctx)
+		if err != nil {
+			return fmt.Errorf("error listing cached events: %w", err)
+		}
+
+		res := make([]CachedEventKey, 0, len(keys))
+		for _, key := range keys {
+			res = append(res, CachedEventKey{Id: key.Id, TTL: int(key.TTL.Seconds())})
+		}
+		slices.SortFunc(res, func(a, b CachedEventKey) int {
+			return strings.Compare(a.Id, b.Id)
+		})
+
+		api.Encode(w, http.StatusOK, res)
+		return nil
+	}
+}
+
+func HandleGetCachedEvent(eventCache cache.EventCacheRepository) api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		entry, err := eventCache.GetEvent(ctx, r.PathValue("id"))
+		if err != nil {
+			return fmt.Errorf("error getting cached event: %w", err)
+		}
+		if entry == nil {
+			http.Error(w, "event not cached", http.StatusNotFound)
+			return nil
+		}
+
+		api.Encode(w, http.StatusOK, entry)
+		return nil
+	}
+}
+
+func HandleDeleteCachedEvent(eventCache cache.EventCacheRepository) api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		id := r.PathValue("id")
+		if err := eventCache.DeleteEvent(ctx, id); err != nil {
+			return fmt.Errorf("error deleting cached event: %w", err)
+		}
+		logs.Logger(ctx).Info("invalidated cached event", "event ID", id)
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+}
+
+func HandleDeleteCachedLatest(eventCache cache.EventCacheRepository) api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		league, ok := leagueFromRequest(w, r)
+		if !ok {
+			return nil
+		}
+
+		if err := eventCache.DeleteEvent(ctx, latestCacheId(league)); err != nil {
+			return fmt.Errorf("error deleting cached latest event: %w", err)
+		}
+		logs.Logger(ctx).Info("invalidated cached latest event", "league", league)
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+}
+
+func HandleDeleteCachedSchedule(eventCache cache.EventCacheRepository) api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		league, ok := leagueFromRequest(w, r)
+		if !ok {
+			return nil
+		}
+
+		if err := eventCache.DeleteSchedule(ctx, league); err != nil {
+			return fmt.Errorf("error deleting cached schedule: %w", err)
+		}
+		logs.Logger(ctx).Info("invalidated cached schedule", "league", league)
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+}
@@ -0,0 +1,129 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/thebenkogan/ufc/internal/model"
+)
+
+// ESPNAPIEventScraper reads events from ESPN's JSON scoreboard API.
+// It shares event IDs with the fightcenter pages and is served by a separate backend,
+// but it is not independent of them, so it only checks results for leagues without another source.
+type ESPNAPIEventScraper struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewESPNAPIEventScraper() *ESPNAPIEventScraper {
+	return &ESPNAPIEventScraper{
+		baseURL: "https://site.api.espn.com/apis/site/v2/sports/mma",
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type espnAPIScoreboard struct {
+	Events []espnAPIEvent `json:"events"`
+}
+
+type espnAPIEvent struct {
+	Id           string `json:"id"`
+	Name         string `json:"name"`
+	Date         string `json:"date"`
+	Competitions []struct {
+		Status struct {
+			Type struct {
+				Completed bool `json:"completed"`
+			} `json:"type"`
+		} `json:"status"`
+		Competitors []struct {
+			Winner  bool `json:"winner"`
+			Athlete struct {
+				DisplayName string `json:"displayName"`
+			} `json:"athlete"`
+		} `json:"competitors"`
+	} `json:"competitions"`
+}
+
+func (e ESPNAPIEventScraper) get(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to visit URL: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status from %s: %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (e ESPNAPIEventScraper) ScrapeEvent(ctx context.Context, league, id string) (*model.Event, error) {
+	var apiEvent espnAPIEvent
+	if id == eventLatest {
+		var scoreboard espnAPIScoreboard
+		if err := e.get(ctx, fmt.Sprintf("%s/%s/scoreboard", e.baseURL, league), &scoreboard); err != nil {
+			return nil, err
+		}
+		if len(scoreboard.Events) == 0 {
+			return nil, fmt.Errorf("no events on %s scoreboard", league)
+		}
+		apiEvent = scoreboard.Events[0]
+	} else {
+		if err := e.get(ctx, fmt.Sprintf("%s/%s/scoreboard/%s", e.baseURL, league, id), &apiEvent); err != nil {
+			return nil, err
+		}
+	}
+
+	event := model.Event{Id: apiEvent.Id, League: league, Name: apiEvent.Name, Fights: make([]model.Fight, 0)}
+	if t, err := time.Parse("2006-01-02T15:04Z", apiEvent.Date); err == nil {
+		event.StartTime = t.UTC().Format(time.RFC3339)
+	}
+	for _, competition := range apiEvent.Competitions {
+		fight := model.Fight{Fighters: make([]string, 0, len(competition.Competitors))}
+		for _, competitor := range competition.Competitors {
+			fight.Fighters = append(fight.Fighters, competitor.Athlete.DisplayName)
+			if competitor.Winner {
+				fight.Winner = competitor.Athlete.DisplayName
+			}
+		}
+		// completed fights without a winner were draws or no contests
+		fight.NoWinner = competition.Status.Type.Completed && fight.Winner == ""
+		event.Fights = append(event.Fights, fight)
+	}
+
+	return &event, nil
+}
+
+func (e ESPNAPIEventScraper) ScrapeResults(ctx context.Context, event *model.Event) (*model.Event, error) {
+	return e.ScrapeEvent(ctx, event.League, event.Id)
+}
+
+func (e ESPNAPIEventScraper) ScrapeSchedule(ctx context.Context, league string) ([]*model.EventInfo, error) {
+	var scoreboard espnAPIScoreboard
+	if err := e.get(ctx, fmt.Sprintf("%s/%s/scoreboard", e.baseURL, league), &scoreboard); err != nil {
+		return nil, err
+	}
+
+	events := make([]*model.EventInfo, 0, len(scoreboard.Events))
+	for _, apiEvent := range scoreboard.Events {
+		t, err := time.Parse("2006-01-02T15:04Z", apiEvent.Date)
+		if err != nil {
+			continue
+		}
+		events = append(events, &model.EventInfo{Id: apiEvent.Id, League: league, Name: apiEvent.Name, Date: t})
+	}
+
+	slices.SortFunc(events, func(a, b *model.EventInfo) int {
+		return a.Date.Compare(b.Date)
+	})
+
+	return events, nil
+}
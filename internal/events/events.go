@@ -2,7 +2,9 @@ package events
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"slices"
 	"sync"
 	"time"
@@ -11,23 +13,62 @@ import (
 	"github.com/thebenkogan/ufc/internal/model"
 	"github.com/thebenkogan/ufc/internal/util/logs"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
 )
 
 const eventLatest string = "latest"
 
-func getEventsWithCache(ctx context.Context, eventScraper EventScraper, eventCache cache.EventCacheRepository, ids []string) (map[string]*model.Event, error) {
+// the latest event differs per league, so its cache entry is keyed by league
+func latestCacheId(league string) string {
+	return eventLatest + "#" + league
+}
+
+func eventCacheId(league, id string) string {
+	if id == eventLatest {
+		return latestCacheId(league)
+	}
+	return id
+}
+
+// ids maps each event ID to the league it belongs to.
+// All IDs are read from the cache at once, and only the misses are scraped.
+func getEventsWithCache(ctx context.Context, eventScraper EventScraper, eventCache cache.EventCacheRepository, ids map[string]string) (map[string]*model.Event, error) {
+	cacheIds := make([]string, 0, len(ids))
+	for id := range ids {
+		cacheIds = append(cacheIds, id)
+	}
+	cached, err := eventCache.GetEvents(ctx, cacheIds)
+	if err != nil {
+		logs.Logger(ctx).Warn("failed to get events from cache", "error", err)
+	}
+
 	events := make(map[string]*model.Event, len(ids))
+	misses := make(map[string]string)
+	for id, league := range ids {
+		entry, ok := cached[id]
+		if !ok {
+			misses[id] = league
+			continue
+		}
+		if entry.IsStale() {
+			refreshEventInBackground(ctx, eventScraper, eventCache, league, id)
+		}
+		events[id] = entry.Event
+	}
+
+	logs.Logger(ctx).Info("got events from cache", "hits", len(events), "misses", len(misses))
+
 	group, gCtx := errgroup.WithContext(ctx)
 	group.SetLimit(5)
 	var mu sync.Mutex
-	for _, id := range ids {
+	for id, league := range misses {
 		group.Go(func() error {
-			event, err := getEventWithCache(gCtx, eventScraper, eventCache, id)
+			entry, err := scrapeAndCacheEventOnce(gCtx, eventScraper, eventCache, league, id, 0)
 			if err != nil {
 				return err
 			}
 			mu.Lock()
-			events[id] = event
+			events[id] = entry.Event
 			mu.Unlock()
 			return nil
 		})
@@ -41,55 +82,222 @@ func getEventsWithCache(ctx context.Context, eventScraper EventScraper, eventCac
 
 }
 
-func getEventWithCache(ctx context.Context, eventScraper EventScraper, eventCache cache.EventCacheRepository, id string) (*model.Event, error) {
-	logs.Logger(ctx).Info(fmt.Sprintf("Getting event, ID: %s", id))
+func getEventWithCache(ctx context.Context, eventScraper EventScraper, eventCache cache.EventCacheRepository, league, id string) (*model.Event, error) {
+	entry, err := getEventEntryWithCache(ctx, eventScraper, eventCache, league, id)
+	if err != nil {
+		return nil, err
+	}
+	return entry.Event, nil
+}
+
+// errEventNotFound is returned for an event of another league than the one requested
+var errEventNotFound = errors.New("event not found")
+
+// Writes a 404 for errEventNotFound and returns nil, other errors are returned as is
+func handleEventError(w http.ResponseWriter, err error) error {
+	if errors.Is(err, errEventNotFound) {
+		http.Error(w, "event not found", http.StatusNotFound)
+		return nil
+	}
+	return err
+}
 
-	cached, err := eventCache.GetEvent(ctx, id)
+// Returns the cached event with its freshness, scraping it on a miss.
+// Stale entries are returned immediately while they are refreshed in the background.
+// Events are cached by ID alone, so errEventNotFound is returned if it belongs to another league.
+func getEventEntryWithCache(ctx context.Context, eventScraper EventScraper, eventCache cache.EventCacheRepository, league, id string) (*cache.CachedEvent, error) {
+	logs.Logger(ctx).Info(fmt.Sprintf("Getting event, league: %s, ID: %s", league, id))
+
+	entry, err := eventCache.GetEvent(ctx, eventCacheId(league, id))
 	if err != nil {
 		logs.Logger(ctx).Warn("failed to get event from cache", "error", err)
 	}
 
-	if cached != nil {
-		logs.Logger(ctx).Info("cache hit")
+	if entry != nil {
+		if entry.IsStale() {
+			logs.Logger(ctx).Info("stale cache hit, refreshing in background", "fetched at", entry.FetchedAt)
+			refreshEventInBackground(ctx, eventScraper, eventCache, league, id)
+		} else {
+			logs.Logger(ctx).Info("cache hit")
+		}
+	} else {
+		logs.Logger(ctx).Info("cache miss, scraping event...")
+		entry, err = scrapeAndCacheEventOnce(ctx, eventScraper, eventCache, league, id, 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if entry.Event.League != league {
+		return nil, errEventNotFound
+	}
+	return entry, nil
+}
+
+func refreshEventInBackground(ctx context.Context, eventScraper EventScraper, eventCache cache.EventCacheRepository, league, id string) {
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		if _, err := scrapeAndCacheEventOnce(ctx, eventScraper, eventCache, league, id, 0); err != nil {
+			logs.Logger(ctx).Warn("failed to refresh stale event", "error", err)
+		}
+	}()
+}
+
+const (
+	scrapeLockTTL  = 30 * time.Second
+	scrapeLockWait = 10 * time.Second
+	scrapeLockPoll = 200 * time.Millisecond
+)
+
+// coalesces concurrent scrapes of the same event within this instance
+var scrapeGroup singleflight.Group
+
+// Scrapes and caches the event so only one scrape per event runs at a time.
+// Concurrent callers in this instance share a single scrape, and across instances
+// the cache lock makes everyone else wait for the lock holder to fill the cache.
+// A cached entry that stays fresh for at least freshFor is returned without scraping.
+func scrapeAndCacheEventOnce(ctx context.Context, eventScraper EventScraper, eventCache cache.EventCacheRepository, league, id string, freshFor time.Duration) (*cache.CachedEvent, error) {
+	// the scrape is shared, so a cancelled caller must not cancel it for everyone else
+	sharedCtx := context.WithoutCancel(ctx)
+	ch := scrapeGroup.DoChan(eventCacheId(league, id), func() (any, error) {
+		return scrapeAndCacheEventLocked(sharedCtx, eventScraper, eventCache, league, id, freshFor)
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		if res.Shared {
+			logs.Logger(ctx).Info("shared in-flight scrape")
+		}
+		return res.Val.(*cache.CachedEvent), nil
+	}
+}
+
+func scrapeAndCacheEventLocked(ctx context.Context, eventScraper EventScraper, eventCache cache.EventCacheRepository, league, id string, freshFor time.Duration) (*cache.CachedEvent, error) {
+	cacheId := eventCacheId(league, id)
+
+	release, acquired, err := eventCache.LockEvent(ctx, cacheId, scrapeLockTTL)
+	if err != nil {
+		logs.Logger(ctx).Warn("failed to lock event, scraping without lock", "error", err)
+		return scrapeAndCacheEvent(ctx, eventScraper, eventCache, league, id)
+	}
+
+	if !acquired {
+		logs.Logger(ctx).Info("event is being scraped by another instance, waiting...")
+		entry, err := waitForCachedEvent(ctx, eventCache, cacheId, freshFor)
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil {
+			return entry, nil
+		}
+		logs.Logger(ctx).Warn("timed out waiting for event, scraping without lock")
+		return scrapeAndCacheEvent(ctx, eventScraper, eventCache, league, id)
+	}
+
+	defer func() {
+		if err := release(ctx); err != nil {
+			logs.Logger(ctx).Warn("failed to release event lock", "error", err)
+		}
+	}()
+
+	// another instance may have refreshed the cache between our read and taking the lock
+	cached, err := eventCache.GetEvent(ctx, cacheId)
+	if err == nil && cached != nil && !cached.IsStaleWithin(freshFor) {
 		return cached, nil
 	}
 
-	logs.Logger(ctx).Info("cache miss, scraping event...")
+	return scrapeAndCacheEvent(ctx, eventScraper, eventCache, league, id)
+}
+
+// Polls the cache until an entry for the event that stays fresh for freshFor appears,
+// returning nil if it does not within scrapeLockWait
+func waitForCachedEvent(ctx context.Context, eventCache cache.EventCacheRepository, cacheId string, freshFor time.Duration) (*cache.CachedEvent, error) {
+	ticker := time.NewTicker(scrapeLockPoll)
+	defer ticker.Stop()
+	timeout := time.After(scrapeLockWait)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timeout:
+			return nil, nil
+		case <-ticker.C:
+			cached, err := eventCache.GetEvent(ctx, cacheId)
+			if err != nil {
+				logs.Logger(ctx).Warn("failed to get event from cache", "error", err)
+				continue
+			}
+			if cached != nil && !cached.IsStaleWithin(freshFor) {
+				return cached, nil
+			}
+		}
+	}
+}
+
+// how long past its freshness a cached event can still be served while it is refreshed
+const staleTime = 24 * time.Hour
 
-	event, err := eventScraper.ScrapeEvent(id)
+// Returns a cache entry for the event that is fresh for ttl, and the hard expiry of the entry
+func newCacheEntry(event *model.Event, ttl time.Duration) (*cache.CachedEvent, time.Duration) {
+	entry := &cache.CachedEvent{Event: event, FetchedAt: time.Now().UTC()}
+	if ttl == 0 {
+		// fresh forever
+		return entry, 0
+	}
+	entry.FreshUntil = entry.FetchedAt.Add(ttl)
+	return entry, ttl + staleTime
+}
+
+// Returns how long clients can reuse the cached event before revalidating it
+func clientMaxAge(entry *cache.CachedEvent) time.Duration {
+	if entry.FreshUntil.IsZero() {
+		return staleTime
+	}
+	return max(time.Until(entry.FreshUntil), 0)
+}
+
+// Scrapes the event and stores it in the cache, regardless of what is currently cached
+func scrapeAndCacheEvent(ctx context.Context, eventScraper EventScraper, eventCache cache.EventCacheRepository, league, id string) (*cache.CachedEvent, error) {
+	event, err := eventScraper.ScrapeEvent(ctx, league, id)
 	if err != nil {
 		return nil, err
 	}
 
 	logs.Logger(ctx).Info("parsed event, storing to cache")
 
-	ttl := freshTime(event)
-	if err := eventCache.SetEvent(ctx, event.Id, event, ttl); err != nil {
+	entry, expiry := newCacheEntry(event, freshTime(event))
+	if err := eventCache.SetEvent(ctx, event.Id, entry, expiry); err != nil {
 		logs.Logger(ctx).Warn("failed to cache event", "error", err)
 	}
 	if id == eventLatest {
 		if event.IsFinished() {
 			// don't cache latest key forever when event is over
-			ttl = time.Hour
+			entry, expiry = newCacheEntry(event, time.Hour)
 		}
-		if err := eventCache.SetEvent(ctx, eventLatest, event, ttl); err != nil {
+		if err := eventCache.SetEvent(ctx, latestCacheId(league), entry, expiry); err != nil {
 			logs.Logger(ctx).Warn("failed to cache latest event", "error", err)
 		}
 
 	}
 
-	return event, nil
+	return entry, nil
 }
 
 const (
-	beforeFreshTime = time.Hour
-	duringFreshTime = 5 * time.Minute
+	beforeFreshTime      = time.Hour
+	duringFreshTime      = 5 * time.Minute
+	unconfirmedFreshTime = 15 * time.Minute
 )
 
 // Returns how long this event should remain in the cache
 // before start time, it is fresh for beforeFreshTime or until event start, whichever is sooner
 // during the event (LIVE), it is fresh for duringFreshTime
-// after the event, it is fresh forever (0)
+// after the event, it is fresh forever (0) once every result is confirmed
 func freshTime(event *model.Event) time.Duration {
 	if event.IsFinished() {
 		// event is over, keep forever
@@ -115,7 +323,8 @@ func freshTime(event *model.Event) time.Duration {
 		}
 	}
 
-	return 0
+	// started but unfinished, results are lagging, unconfirmed, disputed or unresolved
+	return unconfirmedFreshTime
 }
 
 func validatePicks(event *model.Event, picks []string) error {
@@ -145,11 +354,17 @@ func validatePicks(event *model.Event, picks []string) error {
 	return nil
 }
 
+// extra points for correctly picking the underdog of a fight with known odds
+const underdogBonus = 1
+
 func scorePicks(event *model.Event, picks []string) int {
 	score := 0
 	for _, fight := range event.Fights {
-		if slices.Contains(picks, fight.Winner) {
+		if fight.IsDecided() && slices.Contains(picks, fight.Winner) {
 			score++
+			if fight.Winner == fight.Underdog() {
+				score += underdogBonus
+			}
 		}
 	}
 	return score
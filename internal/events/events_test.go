@@ -1,12 +1,29 @@
 package events
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thebenkogan/ufc/internal/cache"
+	"github.com/thebenkogan/ufc/internal/live"
 	"github.com/thebenkogan/ufc/internal/model"
+	"github.com/thebenkogan/ufc/internal/notify"
+	"github.com/thebenkogan/ufc/internal/picks"
+	"github.com/thebenkogan/ufc/internal/resolutions"
+	"github.com/thebenkogan/ufc/internal/users"
+	"golang.org/x/net/websocket"
 )
 
 func TestFreshTime(t *testing.T) {
@@ -18,7 +35,7 @@ func TestFreshTime(t *testing.T) {
 	}{
 		{now.Add(2 * beforeFreshTime), beforeFreshTime},
 		{now.Add(beforeFreshTime / 2), beforeFreshTime / 2},
-		{now.Add(-2 * time.Hour), 0},
+		{now.Add(-2 * time.Hour), unconfirmedFreshTime},
 	}
 
 	for _, tt := range freshTimeTests {
@@ -34,6 +51,14 @@ func TestFreshTime(t *testing.T) {
 		got := freshTime(event)
 		assert.Equal(t, duringFreshTime, got)
 	})
+
+	t.Run("Should keep finished events forever only once confirmed", func(t *testing.T) {
+		startTime := now.Add(-2 * time.Hour).Format(time.RFC3339)
+		confirmed := &model.Event{StartTime: startTime, Fights: []model.Fight{{Fighters: []string{"A", "B"}, Winner: "A"}, {Fighters: []string{"C", "D"}, NoWinner: true}}}
+		assert.Zero(t, freshTime(confirmed))
+		unconfirmed := &model.Event{StartTime: startTime, Fights: []model.Fight{{Fighters: []string{"A", "B"}, Winner: "A", Unconfirmed: true}}}
+		assert.Equal(t, unconfirmedFreshTime, freshTime(unconfirmed))
+	})
 }
 
 func TestValidatePicks(t *testing.T) {
@@ -92,6 +117,818 @@ func TestScorePicks(t *testing.T) {
 			assert.Equal(t, tt.score, got)
 		})
 	}
+
+	oddsEvent := &model.Event{StartTime: "LIVE", Fights: []model.Fight{
+		{Fighters: []string{"A", "B"}, Winner: "A", Odds: map[string]int{"A": 150, "B": -180}},
+		{Fighters: []string{"C", "D"}, Winner: "D", Odds: map[string]int{"C": 200, "D": -250}},
+		{Fighters: []string{"E", "F"}, Winner: "E", Odds: map[string]int{"E": -110, "F": -110}},
+	}}
+
+	oddsScoreTests := []struct {
+		picks []string
+		score int
+	}{
+		{[]string{"A", "D", "E"}, 4},
+		{[]string{"B", "D", "E"}, 2},
+		{[]string{"A"}, 2},
+		{[]string{"E"}, 1},
+	}
+
+	for _, tt := range oddsScoreTests {
+		t.Run(fmt.Sprintf("picks: %v, score: %v", tt.picks, tt.score), func(t *testing.T) {
+			got := scorePicks(oddsEvent, tt.picks)
+			assert.Equal(t, tt.score, got)
+		})
+	}
+}
+
+func TestAttachOdds(t *testing.T) {
+	event := &model.Event{Fights: []model.Fight{
+		{Fighters: []string{"Alex Pereira", "Jamahal Hill"}},
+		{Fighters: []string{"Zhang Weili", "Yan Xiaonan"}},
+	}}
+	attachOdds(event, map[string]int{"alex  pereira": -135, "Jamahal Hill": 114, "Zhang Weili": -550})
+
+	assert.Equal(t, map[string]int{"Alex Pereira": -135, "Jamahal Hill": 114}, event.Fights[0].Odds)
+	assert.Equal(t, "Jamahal Hill", event.Fights[0].Underdog())
+	assert.Nil(t, event.Fights[1].Odds)
+}
+
+type testEventScraper struct {
+	scrapes atomic.Int32
+	delay   time.Duration
+}
+
+func (s *testEventScraper) ScrapeEvent(_ context.Context, league, id string) (*model.Event, error) {
+	s.scrapes.Add(1)
+	time.Sleep(s.delay)
+	return &model.Event{Id: id, League: league, StartTime: time.Now().Add(time.Hour).Format(time.RFC3339), Fights: []model.Fight{}}, nil
+}
+
+func (s *testEventScraper) ScrapeSchedule(_ context.Context, league string) ([]*model.EventInfo, error) {
+	return []*model.EventInfo{{Id: "1", League: league}}, nil
+}
+
+func TestGetEventWithCacheCoalescing(t *testing.T) {
+	t.Run("should scrape once for concurrent misses", func(t *testing.T) {
+		scraper := &testEventScraper{delay: 50 * time.Millisecond}
+		eventCache := cache.NewMemoryEventCache(10)
+
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				event, err := getEventWithCache(context.Background(), scraper, eventCache, model.LeagueUFC, "1")
+				assert.NoError(t, err)
+				assert.Equal(t, "1", event.Id)
+			}()
+		}
+		wg.Wait()
+
+		assert.EqualValues(t, 1, scraper.scrapes.Load())
+	})
+
+	t.Run("should wait for another instance holding the lock", func(t *testing.T) {
+		scraper := &testEventScraper{}
+		eventCache := cache.NewMemoryEventCache(10)
+
+		// another instance takes the lock and fills the cache shortly after
+		release, acquired, _ := eventCache.LockEvent(context.Background(), "2", scrapeLockTTL)
+		assert.True(t, acquired)
+		other := &model.Event{Id: "2", League: model.LeagueUFC, Name: "from other instance"}
+		go func() {
+			time.Sleep(2 * scrapeLockPoll)
+			_ = eventCache.SetEvent(context.Background(), "2", &cache.CachedEvent{Event: other, FetchedAt: time.Now()}, 0)
+			_ = release(context.Background())
+		}()
+
+		event, err := getEventWithCache(context.Background(), scraper, eventCache, model.LeagueUFC, "2")
+		assert.NoError(t, err)
+		assert.Equal(t, other, event)
+		assert.EqualValues(t, 0, scraper.scrapes.Load())
+	})
+}
+
+func TestGetEventWithCacheStale(t *testing.T) {
+	scraper := &testEventScraper{}
+	eventCache := cache.NewMemoryEventCache(10)
+
+	stale := &model.Event{Id: "1", League: model.LeagueUFC, Name: "stale"}
+	_ = eventCache.SetEvent(context.Background(), "1", &cache.CachedEvent{
+		Event:      stale,
+		FetchedAt:  time.Now().Add(-time.Hour),
+		FreshUntil: time.Now().Add(-time.Minute),
+	}, 0)
+
+	entry, err := getEventEntryWithCache(context.Background(), scraper, eventCache, model.LeagueUFC, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, stale, entry.Event)
+	assert.True(t, entry.IsStale())
+
+	assert.Eventually(t, func() bool {
+		refreshed, _ := eventCache.GetEvent(context.Background(), "1")
+		return refreshed.Event != stale && !refreshed.IsStale()
+	}, time.Second, 10*time.Millisecond)
+	assert.EqualValues(t, 1, scraper.scrapes.Load())
+}
+
+func TestHandleGetEventETag(t *testing.T) {
+	ctx := context.Background()
+	eventCache := cache.NewMemoryEventCache(10)
+	event := &model.Event{Id: "1", League: model.LeagueUFC, StartTime: "LIVE", Fights: []model.Fight{}}
+	fetchedAt := time.Now().Add(-time.Minute).UTC()
+	_ = eventCache.SetEvent(ctx, "1", &cache.CachedEvent{Event: event, FetchedAt: fetchedAt, FreshUntil: time.Now().Add(time.Hour)}, 0)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /events/{id}", func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, HandleGetEvent(&testEventScraper{}, eventCache)(r.Context(), w, r))
+	})
+	get := func(etag string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/events/1", nil)
+		if etag != "" {
+			r.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	w := get("")
+	require.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	assert.Equal(t, http.StatusNotModified, get(etag).Code)
+
+	// the same event turning stale is a different representation
+	_ = eventCache.SetEvent(ctx, "1", &cache.CachedEvent{Event: event, FetchedAt: fetchedAt, FreshUntil: time.Now().Add(-time.Second)}, 0)
+	w = get(etag)
+	require.Equal(t, http.StatusOK, w.Code)
+	var res GetEventResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+	assert.True(t, res.Stale)
+}
+
+func TestGetEventsWithCache(t *testing.T) {
+	ctx := context.Background()
+	scraper := &testEventScraper{}
+	eventCache := cache.NewMemoryEventCache(10)
+	for _, id := range []string{"1", "2"} {
+		_ = eventCache.SetEvent(ctx, id, &cache.CachedEvent{Event: &model.Event{Id: id, Name: "cached"}, FetchedAt: time.Now()}, 0)
+	}
+
+	events, err := getEventsWithCache(ctx, scraper, eventCache, map[string]string{"1": model.LeagueUFC, "2": model.LeagueUFC, "3": "pfl"})
+	assert.NoError(t, err)
+	assert.Len(t, events, 3)
+	assert.Equal(t, "cached", events["1"].Name)
+	assert.Equal(t, "cached", events["2"].Name)
+	assert.Equal(t, "pfl", events["3"].League)
+	assert.EqualValues(t, 1, scraper.scrapes.Load())
+}
+
+func TestCacheWarmer(t *testing.T) {
+	ctx := context.Background()
+	scraper := &testEventScraper{}
+	eventCache := cache.NewMemoryEventCache(10)
+
+	warmer := NewCacheWarmer(scraper, eventCache, []string{model.LeagueUFC})
+	warmer.warmSchedules(ctx)
+	warmer.warmLatest(ctx)
+
+	schedule, _ := eventCache.GetSchedule(ctx, model.LeagueUFC)
+	assert.Len(t, schedule, 1)
+	for _, id := range []string{schedule[0].Id, latestCacheId(model.LeagueUFC)} {
+		entry, _ := eventCache.GetEvent(ctx, id)
+		assert.NotNil(t, entry)
+	}
+	assert.EqualValues(t, 2, scraper.scrapes.Load())
+
+	// the scraped events are fresh for an hour, so warming again within that does not scrape
+	warmer.warmLatest(ctx)
+	assert.EqualValues(t, 2, scraper.scrapes.Load())
+}
+
+func TestReconcileResults(t *testing.T) {
+	event := &model.Event{Fights: []model.Fight{
+		{Fighters: []string{"A", "B"}, Winner: "A"},
+		{Fighters: []string{"C", "D"}, Winner: "D"},
+		{Fighters: []string{"E", "F"}, Winner: "E"},
+		{Fighters: []string{"G", "H"}},
+		{Fighters: []string{"I", "J"}, Winner: "I"},
+	}}
+	other := &model.Event{Fights: []model.Fight{
+		{Fighters: []string{"b", "a"}, Winner: "a"},
+		{Fighters: []string{"C", "D"}, Winner: "C"},
+		{Fighters: []string{"E", "F"}},
+		{Fighters: []string{"G", "H"}, Winner: "G"},
+		{Fighters: []string{"I", "J"}, Winner: "J"},
+	}}
+	resolved := []*resolutions.Resolution{{Fighters: []string{"J", "I"}, Winner: "i"}}
+
+	disputed := reconcileResults(event, other, resolved)
+
+	assert.Equal(t, []model.Fight{{Fighters: []string{"C", "D"}, Disputed: true}}, disputed)
+	assert.Equal(t, []model.Fight{
+		{Fighters: []string{"A", "B"}, Winner: "A"},
+		{Fighters: []string{"C", "D"}, Disputed: true},
+		{Fighters: []string{"E", "F"}, Winner: "E", Unconfirmed: true},
+		{Fighters: []string{"G", "H"}},
+		{Fighters: []string{"I", "J"}, Winner: "I"},
+	}, event.Fights)
+
+	t.Run("should keep results while the second source is unavailable", func(t *testing.T) {
+		event := &model.Event{Fights: []model.Fight{
+			{Fighters: []string{"A", "B"}, Winner: "A"},
+			{Fighters: []string{"C", "D"}},
+			{Fighters: []string{"E", "F"}, Winner: "E"},
+		}}
+		resolved := []*resolutions.Resolution{{Fighters: []string{"E", "F"}, Winner: "F"}}
+
+		assert.Empty(t, reconcileResults(event, nil, resolved))
+		assert.Equal(t, []model.Fight{
+			{Fighters: []string{"A", "B"}, Winner: "A", Unconfirmed: true},
+			{Fighters: []string{"C", "D"}},
+			{Fighters: []string{"E", "F"}, Winner: "F"},
+		}, event.Fights)
+	})
+
+	t.Run("should decide draws and no contests reported by the second source", func(t *testing.T) {
+		event := &model.Event{Fights: []model.Fight{
+			{Fighters: []string{"A", "B"}},
+			{Fighters: []string{"C", "D"}, Winner: "C"},
+		}}
+		other := &model.Event{Fights: []model.Fight{
+			{Fighters: []string{"A", "B"}, NoWinner: true},
+			{Fighters: []string{"C", "D"}, NoWinner: true},
+		}}
+
+		disputed := reconcileResults(event, other, nil)
+		assert.Equal(t, []model.Fight{{Fighters: []string{"C", "D"}, Disputed: true}}, disputed)
+		assert.Equal(t, []model.Fight{
+			{Fighters: []string{"A", "B"}, NoWinner: true},
+			{Fighters: []string{"C", "D"}, Disputed: true},
+		}, event.Fights)
+	})
+}
+
+// staticEventScraper returns the same event, or fails when it has none
+type staticEventScraper struct {
+	event *model.Event
+}
+
+func (s staticEventScraper) ScrapeEvent(_ context.Context, _, _ string) (*model.Event, error) {
+	if s.event == nil {
+		return nil, fmt.Errorf("unavailable")
+	}
+	return s.event, nil
+}
+
+func (s staticEventScraper) ScrapeSchedule(_ context.Context, _ string) ([]*model.EventInfo, error) {
+	return nil, nil
+}
+
+func (s staticEventScraper) ScrapeResults(ctx context.Context, event *model.Event) (*model.Event, error) {
+	return s.ScrapeEvent(ctx, event.League, event.Id)
+}
+
+func TestReconcilingScraperSecondaryOutage(t *testing.T) {
+	startTime := time.Now().Add(-2 * time.Hour).Format(time.RFC3339)
+	primary := staticEventScraper{event: &model.Event{Id: "1", StartTime: startTime, Fights: []model.Fight{{Fighters: []string{"A", "B"}, Winner: "A"}}}}
+	scraper := NewReconcilingEventScraper(primary, staticEventScraper{}, &testResolutions{})
+
+	event, err := scraper.ScrapeEvent(context.Background(), model.LeagueUFC, "1")
+	require.NoError(t, err)
+	assert.Equal(t, []model.Fight{{Fighters: []string{"A", "B"}, Winner: "A", Unconfirmed: true}}, event.Fights)
+	assert.False(t, event.IsFinished())
+	assert.Zero(t, scorePicks(event, []string{"A"}))
+	// the results are checked again once the secondary is back
+	assert.Equal(t, unconfirmedFreshTime, freshTime(event))
+}
+
+func TestParseScheduleDate(t *testing.T) {
+	now := time.Date(2024, time.December, 20, 12, 0, 0, 0, time.Local)
+
+	parseTests := []struct {
+		date     string
+		year     int
+		expected time.Time
+	}{
+		{"Dec 21", 0, time.Date(2024, time.December, 21, 0, 0, 0, 0, time.Local)},
+		{"Jan 4", 0, time.Date(2025, time.January, 4, 0, 0, 0, 0, time.Local)},
+		{"Dec 14", 0, time.Date(2024, time.December, 14, 0, 0, 0, 0, time.Local)},
+		{"Jun 15", 0, time.Date(2025, time.June, 15, 0, 0, 0, 0, time.Local)},
+		{"Mar 9", 2019, time.Date(2019, time.March, 9, 0, 0, 0, 0, time.Local)},
+	}
+
+	for _, tt := range parseTests {
+		t.Run(fmt.Sprintf("%s in %d", tt.date, tt.year), func(t *testing.T) {
+			got, err := parseScheduleDate(tt.date, tt.year, now)
+			assert.NoError(t, err)
+			assert.True(t, tt.expected.Equal(got), "expected %v, got %v", tt.expected, got)
+		})
+	}
+
+	_, err := parseScheduleDate("TBD", 0, now)
+	assert.Error(t, err)
+}
+
+func TestScrapeScheduleTable(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body><table>
+			<tr class="Table__TR"><td><span class="date__innerCell">Apr 13</span></td><td class="event__col"><a href="/mma/fightcenter/_/id/600041/league/ufc">UFC 300</a></td></tr>
+			<tr class="Table__TR"><td><span class="date__innerCell">Mar 9</span></td><td class="event__col"><a href="/mma/fightcenter/_/id/600039/league/ufc">UFC 299</a></td></tr>
+			<tr class="Table__TR"><td></td><td class="event__col">header</td></tr>
+		</table></body></html>`)
+	}))
+	defer ts.Close()
+
+	events, err := ESPNEventScraper{}.scrapeScheduleTable(ts.URL, model.LeagueUFC, 2024)
+	require.NoError(t, err)
+	assert.Equal(t, []*model.EventInfo{
+		{Id: "600039", League: model.LeagueUFC, Name: "UFC 299", Date: time.Date(2024, time.March, 9, 0, 0, 0, 0, time.Local)},
+		{Id: "600041", League: model.LeagueUFC, Name: "UFC 300", Date: time.Date(2024, time.April, 13, 0, 0, 0, 0, time.Local)},
+	}, events)
+}
+
+func TestESPNAPIScrapeEvent(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/pfl/scoreboard/123", r.URL.Path)
+		fmt.Fprint(w, `{
+			"id": "123",
+			"name": "PFL 1",
+			"date": "2024-04-04T23:00Z",
+			"competitions": [
+				{"competitors": [{"winner": true, "athlete": {"displayName": "A"}}, {"winner": false, "athlete": {"displayName": "B"}}]},
+				{"competitors": [{"winner": false, "athlete": {"displayName": "C"}}, {"winner": false, "athlete": {"displayName": "D"}}]},
+				{"status": {"type": {"completed": true}}, "competitors": [{"winner": false, "athlete": {"displayName": "E"}}, {"winner": false, "athlete": {"displayName": "F"}}]}
+			]
+		}`)
+	}))
+	defer ts.Close()
+
+	scraper := ESPNAPIEventScraper{baseURL: ts.URL, client: ts.Client()}
+	event, err := scraper.ScrapeEvent(context.Background(), "pfl", "123")
+	assert.NoError(t, err)
+	assert.Equal(t, &model.Event{
+		Id:        "123",
+		League:    "pfl",
+		Name:      "PFL 1",
+		StartTime: "2024-04-04T23:00:00Z",
+		Fights: []model.Fight{
+			{Fighters: []string{"A", "B"}, Winner: "A"},
+			{Fighters: []string{"C", "D"}},
+			{Fighters: []string{"E", "F"}, NoWinner: true},
+		},
+	}, event)
+}
+
+func TestUFCStatsScrapeResults(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/statistics/events/completed":
+			fmt.Fprintf(w, `<html><body><table>
+				<tr class="b-statistics__table-row"><td></td></tr>
+				<tr class="b-statistics__table-row"><td><i><a class="b-link" href="%[1]s/event-details/300">UFC 300</a><span class="b-statistics__date"> April 13, 2024 </span></i></td></tr>
+				<tr class="b-statistics__table-row"><td><i><a class="b-link" href="%[1]s/event-details/299">UFC 299</a><span class="b-statistics__date">March 9, 2024</span></i></td></tr>
+			</table></body></html>`, ts.URL)
+		case "/statistics/events/upcoming":
+			fmt.Fprint(w, `<html><body><table></table></body></html>`)
+		case "/event-details/300":
+			fmt.Fprint(w, `<html><body><table>
+				<thead><tr class="b-fight-details__table-row"><th>W/L</th><th>Fighter</th></tr></thead>
+				<tr class="b-fight-details__table-row"><td><i class="b-flag__text">win</i></td><td><p><a>Alex Pereira</a></p><p><a>Jamahal Hill</a></p></td></tr>
+				<tr class="b-fight-details__table-row"><td><i class="b-flag__text">draw</i><i class="b-flag__text">draw</i></td><td><p><a>C</a></p><p><a>D</a></p></td></tr>
+				<tr class="b-fight-details__table-row"><td><i class="b-flag__text">nc</i><i class="b-flag__text">nc</i></td><td><p><a>E</a></p><p><a>F</a></p></td></tr>
+				<tr class="b-fight-details__table-row"><td></td><td><p><a>G</a></p><p><a>H</a></p></td></tr>
+			</table></body></html>`)
+		default:
+			t.Errorf("unexpected request for %s", r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	source := UFCStatsResultSource{baseURL: ts.URL}
+	event := &model.Event{Id: "600041", League: model.LeagueUFC, Name: "UFC 300", StartTime: "2024-04-13T22:00:00Z"}
+	results, err := source.ScrapeResults(context.Background(), event)
+	require.NoError(t, err)
+	assert.Equal(t, &model.Event{
+		Id:        "600041",
+		League:    model.LeagueUFC,
+		Name:      "UFC 300",
+		StartTime: "2024-04-13T22:00:00Z",
+		Fights: []model.Fight{
+			{Fighters: []string{"Alex Pereira", "Jamahal Hill"}, Winner: "Alex Pereira"},
+			{Fighters: []string{"C", "D"}, NoWinner: true},
+			{Fighters: []string{"E", "F"}, NoWinner: true},
+			{Fighters: []string{"G", "H"}},
+		},
+	}, results)
+
+	_, err = source.ScrapeResults(context.Background(), &model.Event{StartTime: "2024-05-04T22:00:00Z"})
+	assert.Error(t, err, "should not match an event days away")
+}
+
+func TestChangedResults(t *testing.T) {
+	previous := &model.Event{Fights: []model.Fight{
+		{Fighters: []string{"A", "B"}, Winner: "A"},
+		{Fighters: []string{"C", "D"}},
+		{Fighters: []string{"E", "F"}},
+		{Fighters: []string{"G", "H"}, Disputed: true},
+	}}
+	event := &model.Event{Fights: []model.Fight{
+		{Fighters: []string{"A", "B"}, Winner: "A"},
+		{Fighters: []string{"C", "D"}, Winner: "D"},
+		{Fighters: []string{"E", "F"}},
+		{Fighters: []string{"G", "H"}, Winner: "G"},
+		{Fighters: []string{"I", "J"}},
+	}}
+
+	assert.Equal(t, []model.Fight{
+		{Fighters: []string{"C", "D"}, Winner: "D"},
+		{Fighters: []string{"G", "H"}, Winner: "G"},
+	}, changedResults(previous, event))
+	assert.Empty(t, changedResults(event, event))
+}
+
+func TestHandleStreamEvent(t *testing.T) {
+	ctx := context.Background()
+	eventCache := cache.NewMemoryEventCache(10)
+	broker := live.NewMemoryBroker()
+	event := &model.Event{Id: "1", League: model.LeagueUFC, StartTime: "LIVE", Fights: []model.Fight{{Fighters: []string{"A", "B"}}}}
+	entry, _ := newCacheEntry(event, time.Hour)
+	_ = eventCache.SetEvent(ctx, "1", entry, 0)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /events/{id}/stream", func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, HandleStreamEvent(&testEventScraper{}, eventCache, broker)(r.Context(), w, r))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/events/1/stream")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+	reader := bufio.NewReader(res.Body)
+
+	readUpdate := func() *live.Update {
+		var update live.Update
+		for {
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				require.NoError(t, json.Unmarshal([]byte(data), &update))
+				return &update
+			}
+		}
+	}
+
+	assert.Equal(t, event, readUpdate().Event)
+
+	finished := &model.Event{Id: "1", League: model.LeagueUFC, StartTime: "LIVE", Fights: []model.Fight{{Fighters: []string{"A", "B"}, Winner: "B"}}}
+	_ = broker.Publish(ctx, &live.Update{Event: finished, Changed: finished.Fights})
+
+	update := readUpdate()
+	assert.Equal(t, finished, update.Event)
+	assert.Equal(t, finished.Fights, update.Changed)
+}
+
+func TestLeaderboard(t *testing.T) {
+	event := &model.Event{Fights: []model.Fight{
+		{Fighters: []string{"A", "B"}, Winner: "A"},
+		{Fighters: []string{"C", "D"}, Winner: "D"},
+		{Fighters: []string{"E", "F"}},
+	}}
+	eventPicks := []*picks.Picks{
+		{UserId: "3", Winners: []string{"B", "C", "E"}},
+		{UserId: "2", Winners: []string{"A", "C", "E"}},
+		{UserId: "1", Winners: []string{"A", "D", "E"}},
+		{UserId: "4", Winners: []string{"A", "C", "F"}},
+	}
+
+	names := map[string]string{"1": "One", "2": "Two", "3": "Three"}
+
+	before := leaderboard(event, eventPicks, names)
+	assert.Equal(t, []Standing{
+		{Rank: 1, UserId: "1", DisplayName: "One", Score: 2},
+		{Rank: 2, UserId: "2", DisplayName: "Two", Score: 1},
+		{Rank: 2, UserId: "4", Score: 1},
+		{Rank: 4, UserId: "3", DisplayName: "Three", Score: 0},
+	}, before)
+
+	event.Fights[2].Winner = "F"
+	after := leaderboard(event, eventPicks, names)
+	assert.Equal(t, []Standing{
+		{Rank: 1, UserId: "4", Score: 2},
+		{Rank: 3, UserId: "2", DisplayName: "Two", Score: 1},
+	}, rankChanges(before, after))
+}
+
+type testEventPicks struct {
+	picks.EventPicksRepository
+	picks []*picks.Picks
+}
+
+func (p *testEventPicks) GetPicksByFilter(_ context.Context, _ *picks.PicksFilter) ([]*picks.Picks, error) {
+	return p.picks, nil
+}
+
+type testUserRepo struct {
+	users.UserRepository
+	users []*users.User
+}
+
+func (u *testUserRepo) GetUsers(_ context.Context, ids []string) ([]*users.User, error) {
+	found := make([]*users.User, 0, len(ids))
+	for _, user := range u.users {
+		if slices.Contains(ids, user.Id) {
+			found = append(found, user)
+		}
+	}
+	return found, nil
+}
+
+func TestHandleLeaderboard(t *testing.T) {
+	ctx := context.Background()
+	eventCache := cache.NewMemoryEventCache(10)
+	broker := live.NewMemoryBroker()
+	event := &model.Event{Id: "1", League: model.LeagueUFC, StartTime: "LIVE", Fights: []model.Fight{{Fighters: []string{"A", "B"}}}}
+	entry, _ := newCacheEntry(event, time.Hour)
+	_ = eventCache.SetEvent(ctx, "1", entry, 0)
+	eventPicks := &testEventPicks{picks: []*picks.Picks{
+		{UserId: "1", Winners: []string{"A"}},
+		{UserId: "2", Winners: []string{"B"}},
+	}}
+	displayName := "Bones"
+	userRepo := &testUserRepo{users: []*users.User{
+		{Id: "1", Name: "User One", DisplayName: &displayName},
+		{Id: "2", Name: "User Two"},
+	}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /events/{id}/leaderboard", func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, HandleLeaderboard(&testEventScraper{}, eventCache, eventPicks, userRepo, broker, []string{"http://localhost:5173"})(r.Context(), w, r))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/events/1/leaderboard"
+
+	// pages on other sites cannot open the leaderboard with the user's cookie
+	_, err := websocket.Dial(wsURL, "", "https://evil.example.com")
+	var dialErr *websocket.DialError
+	require.ErrorAs(t, err, &dialErr)
+	assert.Equal(t, websocket.ErrBadStatus, dialErr.Err)
+
+	for _, origin := range []string{"http://localhost:5173", ts.URL} {
+		ws, err := websocket.Dial(wsURL, "", origin)
+		require.NoError(t, err)
+		ws.Close()
+	}
+
+	ws, err := websocket.Dial(wsURL, "", ts.URL)
+	require.NoError(t, err)
+	defer ws.Close()
+
+	var msg LeaderboardMessage
+	require.NoError(t, websocket.JSON.Receive(ws, &msg))
+	assert.Equal(t, LeaderboardMessage{Type: leaderboardStandings, Standings: []Standing{
+		{Rank: 1, UserId: "1", DisplayName: "Bones", Score: 0},
+		{Rank: 1, UserId: "2", DisplayName: "User Two", Score: 0},
+	}}, msg)
+
+	finished := &model.Event{Id: "1", League: model.LeagueUFC, StartTime: "LIVE", Fights: []model.Fight{{Fighters: []string{"A", "B"}, Winner: "B"}}}
+	_ = broker.Publish(ctx, &live.Update{Event: finished, Changed: finished.Fights})
+
+	msg = LeaderboardMessage{}
+	require.NoError(t, websocket.JSON.Receive(ws, &msg))
+	assert.Equal(t, LeaderboardMessage{Type: leaderboardChanges, Standings: []Standing{
+		{Rank: 1, UserId: "2", DisplayName: "User Two", Score: 1},
+		{Rank: 2, UserId: "1", DisplayName: "Bones", Score: 0},
+	}, Fights: finished.Fights}, msg)
+}
+
+type testPreferences struct {
+	notify.PreferencesRepository
+	recipients []*notify.Preferences
+	claimed    map[string]bool
+}
+
+func (p *testPreferences) GetReminderRecipients(_ context.Context) ([]*notify.Preferences, error) {
+	return p.recipients, nil
+}
+
+func (p *testPreferences) ClaimReminder(_ context.Context, userId, eventId, channel string) (bool, error) {
+	key := userId + eventId + channel
+	if p.claimed[key] {
+		return false, nil
+	}
+	p.claimed[key] = true
+	return true, nil
+}
+
+type testChannel struct {
+	sent []string
+}
+
+func (c *testChannel) Name() string {
+	return notify.ChannelEmail
+}
+
+func (c *testChannel) Send(_ context.Context, to *notify.Recipient, msg *notify.Message) error {
+	c.sent = append(c.sent, to.Email+": "+msg.Subject)
+	return nil
+}
+
+func TestPickReminder(t *testing.T) {
+	ctx := context.Background()
+	eventCache := cache.NewMemoryEventCache(10)
+	startTime := time.Now().Add(12 * time.Hour).UTC()
+	event := &model.Event{Id: "1", League: model.LeagueUFC, Name: "UFC 300", StartTime: startTime.Format(time.RFC3339), Fights: []model.Fight{}}
+	entry, _ := newCacheEntry(event, time.Hour)
+	_ = eventCache.SetEvent(ctx, "1", entry, 0)
+	_ = eventCache.SetSchedule(ctx, model.LeagueUFC, []*model.EventInfo{
+		{Id: "0", League: model.LeagueUFC, Date: startTime.AddDate(0, 0, -7).Truncate(24 * time.Hour)},
+		{Id: "1", League: model.LeagueUFC, Date: startTime.Truncate(24 * time.Hour)},
+		{Id: "2", League: model.LeagueUFC, Date: startTime.AddDate(0, 1, 0).Truncate(24 * time.Hour)},
+	}, 0)
+
+	preferences := &testPreferences{
+		recipients: []*notify.Preferences{
+			{UserId: "1", Email: "due@example.com", HoursBefore: 24, Channels: []string{notify.ChannelEmail}},
+			{UserId: "2", Email: "later@example.com", HoursBefore: 6, Channels: []string{notify.ChannelEmail}},
+			{UserId: "3", Email: "picked@example.com", HoursBefore: 24, Channels: []string{notify.ChannelEmail}},
+		},
+		claimed: make(map[string]bool),
+	}
+	eventPicks := &testEventPicks{picks: []*picks.Picks{{UserId: "3", EventId: "1", Winners: []string{"A"}}}}
+	channel := &testChannel{}
+
+	scraper := &testEventScraper{}
+	reminder := NewPickReminder(scraper, eventCache, eventPicks, preferences, []notify.Channel{channel}, []string{model.LeagueUFC})
+	reminder.remind(ctx)
+	assert.Equal(t, []string{"due@example.com: Make your picks for UFC 300"}, channel.sent)
+	// past events and events beyond any reminder are not loaded
+	assert.EqualValues(t, 0, scraper.scrapes.Load())
+
+	// reminders are only sent once
+	reminder.remind(ctx)
+	assert.Len(t, channel.sent, 1)
+}
+
+func TestCachedFinishedEvents(t *testing.T) {
+	ctx := context.Background()
+	eventCache := cache.NewMemoryEventCache(10)
+	cacheEvent := func(id, league, startTime string, winner string) {
+		event := &model.Event{Id: id, League: league, StartTime: startTime, Fights: []model.Fight{{Fighters: []string{"A", "B"}, Winner: winner}}}
+		entry, _ := newCacheEntry(event, 0)
+		_ = eventCache.SetEvent(ctx, id, entry, 0)
+	}
+	cacheEvent("1", model.LeagueUFC, "2024-03-09T22:00:00Z", "A")
+	cacheEvent("2", model.LeagueUFC, "2024-04-13T22:00:00Z", "B")
+	cacheEvent("3", model.LeagueUFC, "2024-05-11T22:00:00Z", "")
+	cacheEvent("4", "pfl", "2024-04-04T23:00:00Z", "A")
+	entry, _ := eventCache.GetEvent(ctx, "2")
+	_ = eventCache.SetEvent(ctx, latestCacheId(model.LeagueUFC), entry, 0)
+
+	finished, err := cachedFinishedEvents(ctx, eventCache, model.LeagueUFC)
+	assert.NoError(t, err)
+	ids := make([]string, 0, len(finished))
+	for _, entry := range finished {
+		ids = append(ids, entry.Event.Id)
+	}
+	assert.Equal(t, []string{"2", "1"}, ids)
+}
+
+func TestFightResult(t *testing.T) {
+	assert.Equal(t, "B def. A", fightResult(model.Fight{Fighters: []string{"A", "B"}, Winner: "B"}))
+	assert.Equal(t, "B def. A (unconfirmed)", fightResult(model.Fight{Fighters: []string{"A", "B"}, Winner: "B", Unconfirmed: true}))
+	assert.Equal(t, "A vs. B: draw or no contest", fightResult(model.Fight{Fighters: []string{"A", "B"}, NoWinner: true}))
+	assert.Equal(t, "A vs. B: disputed", fightResult(model.Fight{Fighters: []string{"A", "B"}, Disputed: true}))
+	assert.Equal(t, "A vs. B: no result", fightResult(model.Fight{Fighters: []string{"A", "B"}}))
+}
+
+func TestHandleGetScheduleCalendar(t *testing.T) {
+	ctx := context.Background()
+	eventCache := cache.NewMemoryEventCache(10)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /schedule.ics", func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, HandleGetScheduleCalendar(&testEventScraper{}, eventCache)(r.Context(), w, r))
+	})
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/schedule.ics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// the schedule only has dates, the event starts at the time it was scraped with
+	entry, _ := eventCache.GetEvent(ctx, "1")
+	require.NotNil(t, entry)
+	start, err := time.Parse(time.RFC3339, entry.Event.StartTime)
+	require.NoError(t, err)
+	assert.Contains(t, w.Body.String(), "DTSTART:"+start.UTC().Format("20060102T150405Z"))
+	assert.Contains(t, w.Body.String(), "TRIGGER:-PT1H")
+}
+
+// failingLeagueScraper fails to scrape events of one league
+type failingLeagueScraper struct {
+	*testEventScraper
+	failing string
+	leagues []string
+}
+
+func (s *failingLeagueScraper) ScrapeEvent(ctx context.Context, league, id string) (*model.Event, error) {
+	s.leagues = append(s.leagues, league)
+	if league == s.failing {
+		return nil, fmt.Errorf("unavailable")
+	}
+	return s.testEventScraper.ScrapeEvent(ctx, league, id)
+}
+
+type noopDispatcher struct{}
+
+func (noopDispatcher) Dispatch(_ context.Context, _, _ string, _ any) error {
+	return nil
+}
+
+func TestHandleScoreJobFailure(t *testing.T) {
+	scraper := &failingLeagueScraper{testEventScraper: &testEventScraper{}, failing: "pfl"}
+	h := HandleScoreJob(scraper, cache.NewMemoryEventCache(10), &testEventPicks{}, noopDispatcher{})
+
+	err := h(context.Background(), httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/events/score", nil))
+	assert.ErrorContains(t, err, "pfl")
+	// the other leagues are still scored
+	assert.Equal(t, model.Leagues, scraper.leagues)
+}
+
+type testResolutions struct {
+	saved []*resolutions.Resolution
+}
+
+func (r *testResolutions) GetResolutions(_ context.Context, _ string) ([]*resolutions.Resolution, error) {
+	return nil, nil
+}
+
+func (r *testResolutions) SaveResolution(_ context.Context, resolution *resolutions.Resolution) error {
+	r.saved = append(r.saved, resolution)
+	return nil
+}
+
+func TestHandlePostResolutionLeague(t *testing.T) {
+	ctx := context.Background()
+	eventCache := cache.NewMemoryEventCache(10)
+	for id, league := range map[string]string{"1": model.LeagueUFC, "2": "pfl"} {
+		event := &model.Event{Id: id, League: league, StartTime: "LIVE", Fights: []model.Fight{{Fighters: []string{"A", "B"}}}}
+		entry, _ := newCacheEntry(event, time.Hour)
+		_ = eventCache.SetEvent(ctx, id, entry, 0)
+	}
+	fightResolutions := &testResolutions{}
+
+	mux := http.NewServeMux()
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, HandlePostResolution(&testEventScraper{}, eventCache, fightResolutions)(r.Context(), w, r))
+	}
+	mux.HandleFunc("POST /events/{id}/resolutions", handler)
+	mux.HandleFunc("POST /leagues/{league}/events/{id}/resolutions", handler)
+
+	resolve := func(target string) int {
+		r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{"fighters": ["A", "B"], "winner": "A"}`))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// events of another league than the one in the path cannot be resolved
+	assert.Equal(t, http.StatusNotFound, resolve("/leagues/pfl/events/1/resolutions"))
+	assert.Equal(t, http.StatusNotFound, resolve("/events/2/resolutions"))
+	assert.Empty(t, fightResolutions.saved)
+
+	assert.Equal(t, http.StatusOK, resolve("/leagues/pfl/events/2/resolutions"))
+	require.Len(t, fightResolutions.saved, 1)
+	assert.Equal(t, "2", fightResolutions.saved[0].EventId)
+}
+
+func TestHandleGetEventLeague(t *testing.T) {
+	ctx := context.Background()
+	eventCache := cache.NewMemoryEventCache(10)
+	event := &model.Event{Id: "1", League: "pfl", StartTime: "LIVE", Fights: []model.Fight{}}
+	entry, _ := newCacheEntry(event, time.Hour)
+	_ = eventCache.SetEvent(ctx, "1", entry, 0)
+
+	mux := http.NewServeMux()
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, HandleGetEvent(&testEventScraper{}, eventCache)(r.Context(), w, r))
+	}
+	mux.HandleFunc("GET /events/{id}", handler)
+	mux.HandleFunc("GET /leagues/{league}/events/{id}", handler)
+
+	get := func(target string) int {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, get("/leagues/pfl/events/1"))
+	assert.Equal(t, http.StatusNotFound, get("/leagues/bellator/events/1"))
+	assert.Equal(t, http.StatusNotFound, get("/events/1"))
 }
 
 // func TestScrape(t *testing.T) {
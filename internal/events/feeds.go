@@ -0,0 +1,311 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/thebenkogan/ufc/internal/auth"
+	"github.com/thebenkogan/ufc/internal/cache"
+	"github.com/thebenkogan/ufc/internal/feeds"
+	"github.com/thebenkogan/ufc/internal/model"
+	"github.com/thebenkogan/ufc/internal/notify"
+	"github.com/thebenkogan/ufc/internal/picks"
+	"github.com/thebenkogan/ufc/internal/util/api"
+	"github.com/thebenkogan/ufc/internal/util/logs"
+)
+
+const (
+	// cards run about this long from the first prelim to the main event
+	calendarEventDuration = 6 * time.Hour
+	// the public calendar alarms this long before picks close
+	calendarAlarm = time.Hour
+)
+
+func calendarEvent(info *model.EventInfo, start time.Time) feeds.CalendarEvent {
+	return feeds.CalendarEvent{
+		UID:         fmt.Sprintf("%s-%s@ufc-picks", info.League, info.Id),
+		Summary:     info.Name,
+		Description: "Picks close when the event starts.",
+		Start:       start,
+		Duration:    calendarEventDuration,
+	}
+}
+
+// Returns when each scheduled event starts. Schedules only list the date, so start times
+// come from the events themselves, falling back to the date for events that are live.
+func scheduleStartTimes(ctx context.Context, eventScraper EventScraper, eventCache cache.EventCacheRepository, schedule []*model.EventInfo) (map[string]time.Time, error) {
+	ids := make(map[string]string, len(schedule))
+	for _, info := range schedule {
+		ids[info.Id] = info.League
+	}
+	events, err := getEventsWithCache(ctx, eventScraper, eventCache, ids)
+	if err != nil {
+		return nil, fmt.Errorf("error getting scheduled events: %w", err)
+	}
+
+	starts := make(map[string]time.Time, len(schedule))
+	for _, info := range schedule {
+		starts[info.Id] = info.Date
+		if event, ok := events[info.Id]; ok {
+			if t, err := time.Parse(time.RFC3339, event.StartTime); err == nil {
+				starts[info.Id] = t
+			}
+		}
+	}
+	return starts, nil
+}
+
+func writeCalendar(w http.ResponseWriter, name string, events []feeds.CalendarEvent) error {
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	return feeds.WriteCalendar(w, name, events)
+}
+
+func HandleGetScheduleCalendar(eventScraper EventScraper, eventCache cache.EventCacheRepository) api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		league, ok := leagueFromRequest(w, r)
+		if !ok {
+			return nil
+		}
+
+		schedule, err := getScheduleWithCache(ctx, eventScraper, eventCache, league)
+		if err != nil {
+			return err
+		}
+
+		starts, err := scheduleStartTimes(ctx, eventScraper, eventCache, schedule)
+		if err != nil {
+			return err
+		}
+
+		events := make([]feeds.CalendarEvent, 0, len(schedule))
+		for _, info := range schedule {
+			event := calendarEvent(info, starts[info.Id])
+			event.Alarms = []time.Duration{calendarAlarm}
+			events = append(events, event)
+		}
+
+		api.CacheControl(w, warmScheduleInterval)
+		return writeCalendar(w, strings.ToUpper(league)+" schedule", events)
+	}
+}
+
+// HandleGetUserCalendar serves the schedule of every league to the owner of the feed token.
+// Events they have not picked alarm when their pick reminder would be sent.
+func HandleGetUserCalendar(eventScraper EventScraper, eventCache cache.EventCacheRepository, eventPicks picks.EventPicksRepository, tokens feeds.FeedTokenRepository, preferences notify.PreferencesRepository) api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		userId, err := tokens.GetUserId(ctx, r.PathValue("token"))
+		if err != nil {
+			return fmt.Errorf("error getting feed token: %w", err)
+		}
+		if userId == "" {
+			http.Error(w, "feed not found", http.StatusNotFound)
+			return nil
+		}
+
+		userPicks, err := eventPicks.GetAllUserPicks(ctx, &auth.User{Id: userId})
+		if err != nil {
+			return fmt.Errorf("error getting picks: %w", err)
+		}
+		picked := make(map[string]bool, len(userPicks))
+		for _, p := range userPicks {
+			picked[p.EventId] = len(p.Winners) > 0
+		}
+
+		alarm := notify.DefaultHoursBefore * time.Hour
+		prefs, err := preferences.GetPreferences(ctx, userId)
+		if err != nil {
+			return fmt.Errorf("error getting preferences: %w", err)
+		}
+		if prefs != nil {
+			alarm = time.Duration(prefs.HoursBefore) * time.Hour
+		}
+
+		schedule := make([]*model.EventInfo, 0)
+		for _, league := range model.Leagues {
+			leagueSchedule, err := getScheduleWithCache(ctx, eventScraper, eventCache, league)
+			if err != nil {
+				logs.Logger(ctx).Warn("failed to get schedule for calendar", "league", league, "error", err)
+				continue
+			}
+			schedule = append(schedule, leagueSchedule...)
+		}
+		starts, err := scheduleStartTimes(ctx, eventScraper, eventCache, schedule)
+		if err != nil {
+			return err
+		}
+
+		events := make([]feeds.CalendarEvent, 0, len(schedule))
+		for _, info := range schedule {
+			event := calendarEvent(info, starts[info.Id])
+			if picked[info.Id] {
+				event.Description = "Your picks are in."
+			} else {
+				event.Alarms = []time.Duration{alarm}
+			}
+			events = append(events, event)
+		}
+
+		return writeCalendar(w, "UFC Picks", events)
+	}
+}
+
+// number of events in the results feed
+const resultsFeedSize = 20
+
+func fightResult(fight model.Fight) string {
+	fighters := strings.Join(fight.Fighters, " vs. ")
+	switch {
+	case fight.Winner != "":
+		loser := ""
+		for _, f := range fight.Fighters {
+			if f != fight.Winner {
+				loser = f
+			}
+		}
+		result := fmt.Sprintf("%s def. %s", fight.Winner, loser)
+		if fight.Unconfirmed {
+			result += " (unconfirmed)"
+		}
+		return result
+	case fight.NoWinner:
+		return fighters + ": draw or no contest"
+	case fight.Disputed:
+		return fighters + ": disputed"
+	default:
+		return fighters + ": no result"
+	}
+}
+
+// Returns the newest finished events of the league that are in the cache, newest first
+func cachedFinishedEvents(ctx context.Context, eventCache cache.EventCacheRepository, league string) ([]*cache.CachedEvent, error) {
+	keys, err := eventCache.ListEvents(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(keys))
+	for _, key := range keys {
+		// skip aliases such as the latest event, the event is also cached under its ID
+		if !strings.Contains(key.Id, "#") {
+			ids = append(ids, key.Id)
+		}
+	}
+
+	cached, err := eventCache.GetEvents(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	finished := make([]*cache.CachedEvent, 0)
+	for _, entry := range cached {
+		if entry.Event.League == league && entry.Event.IsFinished() {
+			finished = append(finished, entry)
+		}
+	}
+	slices.SortFunc(finished, func(a, b *cache.CachedEvent) int {
+		return strings.Compare(b.Event.StartTime, a.Event.StartTime)
+	})
+	return finished[:min(len(finished), resultsFeedSize)], nil
+}
+
+func writeFeed(w http.ResponseWriter, feed *feeds.Feed) error {
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	return feeds.WriteFeed(w, feed)
+}
+
+func HandleGetResultsFeed(eventCache cache.EventCacheRepository) api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		league, ok := leagueFromRequest(w, r)
+		if !ok {
+			return nil
+		}
+
+		finished, err := cachedFinishedEvents(ctx, eventCache, league)
+		if err != nil {
+			return fmt.Errorf("error getting finished events: %w", err)
+		}
+
+		entries := make([]*feeds.Entry, 0, len(finished))
+		for _, entry := range finished {
+			results := make([]string, 0, len(entry.Event.Fights))
+			for _, fight := range entry.Event.Fights {
+				results = append(results, fightResult(fight))
+			}
+			entries = append(entries, feeds.NewEntry(
+				fmt.Sprintf("urn:ufc-picks:results:%s", entry.Event.Id),
+				entry.Event.Name,
+				entry.FetchedAt,
+				strings.Join(results, "\n"),
+			))
+		}
+
+		feed := feeds.NewFeed("urn:ufc-picks:results:"+league, strings.ToUpper(league)+" results", feeds.BaseURL(r)+r.URL.Path, entries)
+		api.CacheControl(w, duringFreshTime)
+		return writeFeed(w, feed)
+	}
+}
+
+// HandleGetUserPicksFeed serves the scored picks of the owner of the feed token, newest first
+func HandleGetUserPicksFeed(eventScraper EventScraper, eventCache cache.EventCacheRepository, eventPicks picks.EventPicksRepository, tokens feeds.FeedTokenRepository) api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		userId, err := tokens.GetUserId(ctx, r.PathValue("token"))
+		if err != nil {
+			return fmt.Errorf("error getting feed token: %w", err)
+		}
+		if userId == "" {
+			http.Error(w, "feed not found", http.StatusNotFound)
+			return nil
+		}
+
+		userPicks, err := eventPicks.GetAllUserPicks(ctx, &auth.User{Id: userId})
+		if err != nil {
+			return fmt.Errorf("error getting picks: %w", err)
+		}
+		scored := make([]*picks.Picks, 0, len(userPicks))
+		eventIds := make(map[string]string)
+		for _, p := range userPicks {
+			if p.Score != nil {
+				scored = append(scored, p)
+				eventIds[p.EventId] = p.League
+			}
+		}
+
+		eventMap, err := getEventsWithCache(ctx, eventScraper, eventCache, eventIds)
+		if err != nil {
+			return fmt.Errorf("error getting events from IDs: %w", err)
+		}
+
+		entries := make([]*feeds.Entry, 0, len(scored))
+		for _, p := range scored {
+			event := eventMap[p.EventId]
+			lines := make([]string, 0, len(event.Fights))
+			for _, fight := range event.Fights {
+				mark := " "
+				for _, pick := range p.Winners {
+					if slices.Contains(fight.Fighters, pick) {
+						mark = "✗"
+						if pick == fight.Winner {
+							mark = "✓"
+						}
+					}
+				}
+				lines = append(lines, fmt.Sprintf("%s %s", mark, fightResult(fight)))
+			}
+			startTime, _ := time.Parse(time.RFC3339, event.StartTime)
+			entries = append(entries, feeds.NewEntry(
+				fmt.Sprintf("urn:ufc-picks:picks:%s:%s", userId, event.Id),
+				fmt.Sprintf("%s: %d points", event.Name, *p.Score),
+				startTime,
+				strings.Join(lines, "\n"),
+			))
+		}
+
+		feed := feeds.NewFeed("urn:ufc-picks:picks:"+userId, "My picks", feeds.BaseURL(r)+r.URL.Path, entries)
+		return writeFeed(w, feed)
+	}
+}
@@ -2,9 +2,10 @@ package events
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
-	"os"
+	"slices"
 	"time"
 
 	"github.com/samber/lo"
@@ -12,37 +13,49 @@ import (
 	"github.com/thebenkogan/ufc/internal/cache"
 	"github.com/thebenkogan/ufc/internal/model"
 	"github.com/thebenkogan/ufc/internal/picks"
+	"github.com/thebenkogan/ufc/internal/resolutions"
 	"github.com/thebenkogan/ufc/internal/util/api"
 	"github.com/thebenkogan/ufc/internal/util/conv"
 	"github.com/thebenkogan/ufc/internal/util/logs"
+	"github.com/thebenkogan/ufc/internal/webhooks"
 )
 
 const scheduleTTL = time.Hour
 
+// Returns the league from the request path, defaulting to the UFC for routes
+// without a league segment. Writes a 404 and returns false for unknown leagues.
+func leagueFromRequest(w http.ResponseWriter, r *http.Request) (string, bool) {
+	league := r.PathValue("league")
+	if league == "" {
+		return model.LeagueUFC, true
+	}
+	if !slices.Contains(model.Leagues, league) {
+		http.Error(w, "unknown league", http.StatusNotFound)
+		return "", false
+	}
+	return league, true
+}
+
 func HandleGetSchedule(eventScraper EventScraper, eventCache cache.EventCacheRepository) api.Handler {
 	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-		cached, err := eventCache.GetSchedule(ctx)
-		if err != nil {
-			logs.Logger(ctx).Warn("failed to get schedule from cache", "error", err)
-		}
-
-		if cached != nil {
-			logs.Logger(ctx).Info("cache hit")
-			api.Encode(w, http.StatusOK, cached)
+		league, ok := leagueFromRequest(w, r)
+		if !ok {
 			return nil
 		}
 
-		logs.Logger(ctx).Info("cache miss, scraping schedule...")
-
-		schedule, err := eventScraper.ScrapeSchedule()
+		schedule, err := getScheduleWithCache(ctx, eventScraper, eventCache, league)
 		if err != nil {
 			return err
 		}
 
-		logs.Logger(ctx).Info("parsed schedule, storing to cache")
-
-		if err := eventCache.SetSchedule(ctx, schedule, scheduleTTL); err != nil {
-			logs.Logger(ctx).Warn("failed to cache schedule", "error", err)
+		etag, err := api.ETag(schedule)
+		if err != nil {
+			return err
+		}
+		// the warmer rescrapes the schedule at this interval
+		api.CacheControl(w, warmScheduleInterval)
+		if api.NotModified(w, r, etag, time.Time{}) {
+			return nil
 		}
 
 		api.Encode(w, http.StatusOK, schedule)
@@ -50,14 +63,67 @@ func HandleGetSchedule(eventScraper EventScraper, eventCache cache.EventCacheRep
 	}
 }
 
+type GetEventResponse struct {
+	*model.Event
+	// When the event was scraped, it may be refreshing in the background if stale
+	FetchedAt time.Time `json:"fetched_at"`
+	Stale     bool      `json:"stale"`
+}
+
+func getScheduleWithCache(ctx context.Context, eventScraper EventScraper, eventCache cache.EventCacheRepository, league string) ([]*model.EventInfo, error) {
+	cached, err := eventCache.GetSchedule(ctx, league)
+	if err != nil {
+		logs.Logger(ctx).Warn("failed to get schedule from cache", "error", err)
+	}
+
+	if cached != nil {
+		logs.Logger(ctx).Info("cache hit")
+		return cached, nil
+	}
+
+	logs.Logger(ctx).Info("cache miss, scraping schedule...")
+	return scrapeAndCacheSchedule(ctx, eventScraper, eventCache, league)
+}
+
+func scrapeAndCacheSchedule(ctx context.Context, eventScraper EventScraper, eventCache cache.EventCacheRepository, league string) ([]*model.EventInfo, error) {
+	schedule, err := eventScraper.ScrapeSchedule(ctx, league)
+	if err != nil {
+		return nil, err
+	}
+
+	logs.Logger(ctx).Info("parsed schedule, storing to cache")
+
+	if err := eventCache.SetSchedule(ctx, league, schedule, scheduleTTL); err != nil {
+		logs.Logger(ctx).Warn("failed to cache schedule", "error", err)
+	}
+
+	return schedule, nil
+}
+
 func HandleGetEvent(eventScraper EventScraper, eventCache cache.EventCacheRepository) api.Handler {
 	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		league, ok := leagueFromRequest(w, r)
+		if !ok {
+			return nil
+		}
+
 		id := r.PathValue("id")
-		event, err := getEventWithCache(ctx, eventScraper, eventCache, id)
+		entry, err := getEventEntryWithCache(ctx, eventScraper, eventCache, league, id)
+		if err != nil {
+			return handleEventError(w, err)
+		}
+		res := GetEventResponse{Event: entry.Event, FetchedAt: entry.FetchedAt, Stale: entry.IsStale()}
+		// the ETag covers the freshness too, so clients do not keep a stale flag after a refresh
+		etag, err := api.ETag(res)
 		if err != nil {
 			return err
 		}
-		api.Encode(w, http.StatusOK, event)
+		api.CacheControl(w, clientMaxAge(entry))
+		if api.NotModified(w, r, etag, entry.FetchedAt) {
+			return nil
+		}
+
+		api.Encode(w, http.StatusOK, res)
 		return nil
 	}
 }
@@ -69,10 +135,15 @@ func HandleGetPicks(eventScraper EventScraper, eventCache cache.EventCacheReposi
 			return fmt.Errorf("no user in context")
 		}
 
+		league, ok := leagueFromRequest(w, r)
+		if !ok {
+			return nil
+		}
+
 		eventId := r.PathValue("id")
-		event, err := getEventWithCache(ctx, eventScraper, eventCache, eventId)
+		event, err := getEventWithCache(ctx, eventScraper, eventCache, league, eventId)
 		if err != nil {
-			return err
+			return handleEventError(w, err)
 		}
 
 		userPicks, err := eventPicks.GetUserPicksByEvent(ctx, user, event.Id)
@@ -80,7 +151,7 @@ func HandleGetPicks(eventScraper EventScraper, eventCache cache.EventCacheReposi
 			return err
 		}
 		if userPicks == nil {
-			userPicks = &picks.Picks{UserId: user.Id, EventId: eventId, Winners: []string{}}
+			userPicks = &picks.Picks{UserId: user.Id, EventId: eventId, League: event.League, Winners: []string{}}
 		}
 
 		api.Encode(w, http.StatusOK, userPicks)
@@ -110,13 +181,11 @@ func HandleGetAllPicks(eventScraper EventScraper, eventCache cache.EventCacheRep
 			return nil
 		}
 
-		eventIds := make([]string, 0, len(userPicks))
+		eventIds := make(map[string]string, len(userPicks))
 		for _, pick := range userPicks {
-			eventIds = append(eventIds, pick.EventId)
+			eventIds[pick.EventId] = pick.League
 		}
 
-		fmt.Println("event ids", eventIds)
-
 		eventMap, err := getEventsWithCache(ctx, eventScraper, eventCache, eventIds)
 		if err != nil {
 			return fmt.Errorf("error getting events from IDs: %w", err)
@@ -143,10 +212,15 @@ func HandlePostPicks(eventScraper EventScraper, eventCache cache.EventCacheRepos
 
 		pickedFighters := lo.Uniq(picks.Winners)
 
+		league, ok := leagueFromRequest(w, r)
+		if !ok {
+			return nil
+		}
+
 		id := r.PathValue("id")
-		event, err := getEventWithCache(ctx, eventScraper, eventCache, id)
+		event, err := getEventWithCache(ctx, eventScraper, eventCache, league, id)
 		if err != nil {
-			return err
+			return handleEventError(w, err)
 		}
 
 		if event.HasStarted() {
@@ -164,7 +238,7 @@ func HandlePostPicks(eventScraper EventScraper, eventCache cache.EventCacheRepos
 			return fmt.Errorf("no user in context")
 		}
 
-		if err := eventPicks.SavePicks(ctx, user, event.Id, pickedFighters); err != nil {
+		if err := eventPicks.SavePicks(ctx, user, event.League, event.Id, pickedFighters); err != nil {
 			return fmt.Errorf("error saving picks: %w", err)
 		}
 
@@ -172,51 +246,129 @@ func HandlePostPicks(eventScraper EventScraper, eventCache cache.EventCacheRepos
 	}
 }
 
-func HandleScoreJob(eventScraper EventScraper, eventCache cache.EventCacheRepository, eventPicks picks.EventPicksRepository) api.Handler {
+func HandleScoreJob(eventScraper EventScraper, eventCache cache.EventCacheRepository, eventPicks picks.EventPicksRepository, dispatcher webhooks.Dispatcher) api.Handler {
 	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-		key := r.Header.Get("api-key")
-		cronjobKey := os.Getenv("CRONJOB_API_KEY")
-		if key != cronjobKey {
-			api.Encode(w, http.StatusForbidden, http.StatusText(http.StatusForbidden))
-			return nil
+		total := 0
+		// a league that fails does not stop the others from being scored, but fails the job
+		errs := make([]error, 0)
+		for _, league := range model.Leagues {
+			scored, err := scoreLatestEvent(ctx, eventScraper, eventCache, eventPicks, dispatcher, league)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to score latest %s event: %w", league, err))
+				continue
+			}
+			total += scored
 		}
 
-		latestEvent, err := getEventWithCache(ctx, eventScraper, eventCache, eventLatest)
-		if err != nil {
-			return err
+		if len(errs) > 0 {
+			logs.Logger(ctx).Info("scored picks before failing", "total", total)
+			return errors.Join(errs...)
 		}
-
-		if !latestEvent.IsFinished() {
-			logs.Logger(ctx).Info("latest event is not finished, skipping job")
+		if total == 0 {
 			return nil
 		}
 
-		filter := &picks.PicksFilter{
-			EventIDs: []string{latestEvent.Id},
-			HasScore: conv.Ptr(false),
+		api.Encode(w, http.StatusOK, fmt.Sprintf("scored %d picks", total))
+		return nil
+	}
+}
+
+// Scores the unscored picks for the latest event of the league once it is finished.
+// Returns the number of picks scored.
+func scoreLatestEvent(ctx context.Context, eventScraper EventScraper, eventCache cache.EventCacheRepository, eventPicks picks.EventPicksRepository, dispatcher webhooks.Dispatcher, league string) (int, error) {
+	latestEvent, err := getEventWithCache(ctx, eventScraper, eventCache, league, eventLatest)
+	if err != nil {
+		return 0, err
+	}
+
+	if !latestEvent.IsFinished() {
+		logs.Logger(ctx).Info("latest event is not finished, skipping league", "league", league)
+		return 0, nil
+	}
+
+	filter := &picks.PicksFilter{
+		EventIDs: []string{latestEvent.Id},
+		HasScore: conv.Ptr(false),
+	}
+	allPicks, err := eventPicks.GetPicksByFilter(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(allPicks) == 0 {
+		logs.Logger(ctx).Info("all picks scored, skipping league", "league", league)
+		return 0, nil
+	}
+
+	logs.Logger(ctx).Info("scoring picks", "total", len(allPicks), "event ID", latestEvent.Id, "league", league)
+
+	for _, p := range allPicks {
+		p.Score = conv.Ptr(scorePicks(latestEvent, p.Winners))
+	}
+
+	errs := eventPicks.BatchScorePicks(ctx, allPicks)
+	if len(errs) > 0 {
+		logs.Logger(ctx).Warn("failed to save some picks", "errors", errs)
+	}
+
+	data := webhooks.ScoresPostedData{EventId: latestEvent.Id, Scored: len(allPicks) - len(errs)}
+	if err := dispatcher.Dispatch(ctx, league, webhooks.EventScoresPosted, data); err != nil {
+		logs.Logger(ctx).Warn("failed to dispatch scores posted", "event ID", latestEvent.Id, "error", err)
+	}
+
+	return len(allPicks), nil
+}
+
+type PostResolutionRequest struct {
+	Fighters []string `json:"fighters"`
+	Winner   string   `json:"winner"`
+}
+
+func HandlePostResolution(eventScraper EventScraper, eventCache cache.EventCacheRepository, fightResolutions resolutions.FightResolutionRepository) api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		var req PostResolutionRequest
+		api.Decode(r, &req)
+
+		league, ok := leagueFromRequest(w, r)
+		if !ok {
+			return nil
 		}
-		allPicks, err := eventPicks.GetPicksByFilter(ctx, filter)
+
+		id := r.PathValue("id")
+		event, err := getEventWithCache(ctx, eventScraper, eventCache, league, id)
 		if err != nil {
-			return err
+			return handleEventError(w, err)
 		}
 
-		if len(allPicks) == 0 {
-			logs.Logger(ctx).Info("all picks scored, skipping job")
+		key := fightKey(req.Fighters)
+		idx := slices.IndexFunc(event.Fights, func(f model.Fight) bool {
+			return fightKey(f.Fighters) == key
+		})
+		if idx == -1 {
+			http.Error(w, "fight not found", http.StatusBadRequest)
+			return nil
+		}
+		if !slices.ContainsFunc(event.Fights[idx].Fighters, func(f string) bool {
+			return normalizeFighter(f) == normalizeFighter(req.Winner)
+		}) {
+			http.Error(w, "winner is not in the fight", http.StatusBadRequest)
 			return nil
 		}
 
-		logs.Logger(ctx).Info("scoring picks", "total", len(allPicks), "event ID", latestEvent.Id)
-
-		for _, p := range allPicks {
-			p.Score = conv.Ptr(scorePicks(latestEvent, p.Winners))
+		resolution := &resolutions.Resolution{EventId: event.Id, Fighters: event.Fights[idx].Fighters, Winner: req.Winner}
+		if err := fightResolutions.SaveResolution(ctx, resolution); err != nil {
+			return fmt.Errorf("error saving resolution: %w", err)
 		}
 
-		errs := eventPicks.BatchScorePicks(ctx, allPicks)
-		if len(errs) > 0 {
-			logs.Logger(ctx).Warn("failed to save some picks", "errors", errs)
+		logs.Logger(ctx).Info("resolved fight, refreshing event", "event ID", event.Id, "fighters", resolution.Fighters, "winner", resolution.Winner)
+
+		// the cached event still has the unresolved result
+		refreshed, err := scrapeAndCacheEvent(ctx, eventScraper, eventCache, event.League, event.Id)
+		if err != nil {
+			return err
 		}
 
-		api.Encode(w, http.StatusOK, fmt.Sprintf("scored %d picks", len(allPicks)))
+		api.Encode(w, http.StatusOK, refreshed.Event)
 		return nil
 	}
 }
@@ -0,0 +1,189 @@
+package events
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/samber/lo"
+	"github.com/thebenkogan/ufc/internal/cache"
+	"github.com/thebenkogan/ufc/internal/live"
+	"github.com/thebenkogan/ufc/internal/model"
+	"github.com/thebenkogan/ufc/internal/picks"
+	"github.com/thebenkogan/ufc/internal/users"
+	"github.com/thebenkogan/ufc/internal/util/api"
+	"github.com/thebenkogan/ufc/internal/util/logs"
+	"golang.org/x/net/websocket"
+)
+
+type Standing struct {
+	// users with the same score share a rank
+	Rank   int    `json:"rank"`
+	UserId string `json:"user_id"`
+	// the user's display name, or their name if they have not set one
+	DisplayName string `json:"display_name"`
+	Score       int    `json:"score"`
+}
+
+// Returns the provisional standings of everyone who picked the event, best first.
+// names maps user IDs to the names shown for them.
+func leaderboard(event *model.Event, eventPicks []*picks.Picks, names map[string]string) []Standing {
+	standings := make([]Standing, 0, len(eventPicks))
+	for _, p := range eventPicks {
+		standings = append(standings, Standing{UserId: p.UserId, DisplayName: names[p.UserId], Score: scorePicks(event, p.Winners)})
+	}
+	slices.SortFunc(standings, func(a, b Standing) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), cmp.Compare(a.UserId, b.UserId))
+	})
+	for i := range standings {
+		if i > 0 && standings[i].Score == standings[i-1].Score {
+			standings[i].Rank = standings[i-1].Rank
+		} else {
+			standings[i].Rank = i + 1
+		}
+	}
+	return standings
+}
+
+// Returns the names shown on the leaderboard for the users who picked the event
+func displayNames(ctx context.Context, userRepo users.UserRepository, eventPicks []*picks.Picks) (map[string]string, error) {
+	pickers, err := userRepo.GetUsers(ctx, lo.Map(eventPicks, func(p *picks.Picks, _ int) string { return p.UserId }))
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(pickers))
+	for _, u := range pickers {
+		names[u.Id] = u.Profile().Name
+	}
+	return names, nil
+}
+
+// Returns the standings in after whose rank or score differs from before
+func rankChanges(before, after []Standing) []Standing {
+	previous := make(map[string]Standing, len(before))
+	for _, s := range before {
+		previous[s.UserId] = s
+	}
+	changes := make([]Standing, 0)
+	for _, s := range after {
+		if previous[s.UserId] != s {
+			changes = append(changes, s)
+		}
+	}
+	return changes
+}
+
+const (
+	leaderboardStandings = "standings"
+	leaderboardChanges   = "changes"
+)
+
+type LeaderboardMessage struct {
+	// "standings" for the full leaderboard, sent first, then "changes" for the
+	// standings that moved when a fight result landed
+	Type      string        `json:"type"`
+	Standings []Standing    `json:"standings"`
+	Fights    []model.Fight `json:"fights,omitempty"`
+}
+
+// HandleLeaderboard streams the leaderboard of the event over a WebSocket. The session cookie is
+// sent with handshakes from any site, so browsers may only connect from the allowed origins.
+func HandleLeaderboard(eventScraper EventScraper, eventCache cache.EventCacheRepository, eventPicks picks.EventPicksRepository, userRepo users.UserRepository, broker live.Broker, allowedOrigins []string) api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		league, ok := leagueFromRequest(w, r)
+		if !ok {
+			return nil
+		}
+
+		eventId := r.PathValue("id")
+		if eventId == eventLatest {
+			event, err := getEventWithCache(ctx, eventScraper, eventCache, league, eventId)
+			if err != nil {
+				return handleEventError(w, err)
+			}
+			eventId = event.Id
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		// subscribe before reading the event so no update between the two is missed
+		updates := broker.Subscribe(ctx, eventId)
+		event, err := getEventWithCache(ctx, eventScraper, eventCache, league, eventId)
+		if err != nil {
+			return handleEventError(w, err)
+		}
+
+		filter := &picks.PicksFilter{EventIDs: []string{eventId}}
+		allPicks, err := eventPicks.GetPicksByFilter(ctx, filter)
+		if err != nil {
+			return err
+		}
+		names, err := displayNames(ctx, userRepo, allPicks)
+		if err != nil {
+			return fmt.Errorf("error getting leaderboard names: %w", err)
+		}
+
+		handshake := func(_ *websocket.Config, r *http.Request) error {
+			if !api.AllowedOrigin(r, allowedOrigins) {
+				logs.Logger(ctx).Warn("rejected cross-origin leaderboard", "origin", api.RequestOrigin(r))
+				return fmt.Errorf("origin not allowed: %s", api.RequestOrigin(r))
+			}
+			return nil
+		}
+
+		websocket.Server{Handshake: handshake, Handler: func(ws *websocket.Conn) {
+			// the client never sends anything, reading only detects when it goes away
+			go func() {
+				defer cancel()
+				var discard []byte
+				for websocket.Message.Receive(ws, &discard) == nil {
+				}
+			}()
+
+			standings := leaderboard(event, allPicks, names)
+			if err := websocket.JSON.Send(ws, LeaderboardMessage{Type: leaderboardStandings, Standings: standings}); err != nil {
+				logs.Logger(ctx).Info("leaderboard closed", "error", err)
+				return
+			}
+
+			logs.Logger(ctx).Info("streaming leaderboard", "event ID", eventId, "users", len(standings))
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case update, ok := <-updates:
+					if !ok {
+						return
+					}
+					// picks are closed once results land, but may have changed since the stream began
+					allPicks, err := eventPicks.GetPicksByFilter(ctx, filter)
+					if err != nil {
+						logs.Logger(ctx).Warn("failed to get picks for leaderboard", "event ID", eventId, "error", err)
+						continue
+					}
+					if updated, err := displayNames(ctx, userRepo, allPicks); err != nil {
+						logs.Logger(ctx).Warn("failed to get leaderboard names", "event ID", eventId, "error", err)
+					} else {
+						names = updated
+					}
+					next := leaderboard(update.Event, allPicks, names)
+					msg := LeaderboardMessage{Type: leaderboardChanges, Standings: rankChanges(standings, next), Fights: update.Changed}
+					standings = next
+					if len(msg.Standings) == 0 && len(msg.Fights) == 0 {
+						continue
+					}
+					if err := websocket.JSON.Send(ws, msg); err != nil {
+						logs.Logger(ctx).Info("leaderboard closed", "error", err)
+						return
+					}
+				}
+			}
+		}}.ServeHTTP(w, r)
+
+		return nil
+	}
+}
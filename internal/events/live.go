@@ -0,0 +1,175 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/thebenkogan/ufc/internal/cache"
+	"github.com/thebenkogan/ufc/internal/live"
+	"github.com/thebenkogan/ufc/internal/model"
+	"github.com/thebenkogan/ufc/internal/util/api"
+	"github.com/thebenkogan/ufc/internal/util/logs"
+)
+
+// NotifyingEventScraper publishes the fight results that changed since the event was last cached,
+// and when the event started.
+// Scrapes are coalesced across instances, so each change is published once.
+type NotifyingEventScraper struct {
+	scraper    EventScraper
+	eventCache cache.EventCacheRepository
+	broker     live.Broker
+}
+
+func NewNotifyingEventScraper(scraper EventScraper, eventCache cache.EventCacheRepository, broker live.Broker) *NotifyingEventScraper {
+	return &NotifyingEventScraper{
+		scraper:    scraper,
+		eventCache: eventCache,
+		broker:     broker,
+	}
+}
+
+func (s *NotifyingEventScraper) ScrapeEvent(ctx context.Context, league, id string) (*model.Event, error) {
+	event, err := s.scraper.ScrapeEvent(ctx, league, id)
+	if err != nil {
+		return nil, err
+	}
+
+	cached, err := s.eventCache.GetEvent(ctx, event.Id)
+	if err != nil {
+		logs.Logger(ctx).Warn("failed to get previous event from cache", "event ID", event.Id, "error", err)
+		return event, nil
+	}
+	if cached == nil {
+		// nobody has seen the event yet, so nothing changed for them
+		return event, nil
+	}
+
+	changed := changedResults(cached.Event, event)
+	started := startedSince(cached, event)
+	if len(changed) > 0 || started {
+		logs.Logger(ctx).Info("event changed, publishing update", "event ID", event.Id, "changed", len(changed), "started", started)
+		if err := s.broker.Publish(ctx, &live.Update{Event: event, Changed: changed, Started: started}); err != nil {
+			logs.Logger(ctx).Warn("failed to publish result update", "event ID", event.Id, "error", err)
+		}
+	}
+
+	return event, nil
+}
+
+func (s *NotifyingEventScraper) ScrapeSchedule(ctx context.Context, league string) ([]*model.EventInfo, error) {
+	return s.scraper.ScrapeSchedule(ctx, league)
+}
+
+// Returns the fights of event whose result, dispute or confirmation differs from previous
+func changedResults(previous, event *model.Event) []model.Fight {
+	before := make(map[string]model.Fight, len(previous.Fights))
+	for _, fight := range previous.Fights {
+		before[fightKey(fight.Fighters)] = fight
+	}
+
+	changed := make([]model.Fight, 0)
+	for _, fight := range event.Fights {
+		old, ok := before[fightKey(fight.Fighters)]
+		if !ok {
+			// new bouts only matter once they have a result
+			if fight.Winner != "" || fight.NoWinner || fight.Disputed {
+				changed = append(changed, fight)
+			}
+			continue
+		}
+		if old.Winner != fight.Winner || old.NoWinner != fight.NoWinner || old.Disputed != fight.Disputed || old.Unconfirmed != fight.Unconfirmed {
+			changed = append(changed, fight)
+		}
+	}
+	return changed
+}
+
+// Reports whether the event has started, but had not when the cached entry was fetched
+func startedSince(cached *cache.CachedEvent, event *model.Event) bool {
+	if !event.HasStarted() || cached.Event.StartTime == "LIVE" {
+		return false
+	}
+	startTime, err := time.Parse(time.RFC3339, cached.Event.StartTime)
+	return err == nil && startTime.After(cached.FetchedAt)
+}
+
+// keeps proxies from closing idle streams between fights
+const streamKeepAlive = 30 * time.Second
+
+func HandleStreamEvent(eventScraper EventScraper, eventCache cache.EventCacheRepository, broker live.Broker) api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		league, ok := leagueFromRequest(w, r)
+		if !ok {
+			return nil
+		}
+
+		eventId := r.PathValue("id")
+		if eventId == eventLatest {
+			event, err := getEventWithCache(ctx, eventScraper, eventCache, league, eventId)
+			if err != nil {
+				return handleEventError(w, err)
+			}
+			eventId = event.Id
+		}
+
+		// subscribe before reading the event so no update between the two is missed
+		updates := broker.Subscribe(ctx, eventId)
+		event, err := getEventWithCache(ctx, eventScraper, eventCache, league, eventId)
+		if err != nil {
+			return handleEventError(w, err)
+		}
+
+		rc := http.NewResponseController(w)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		send := func(update *live.Update) error {
+			if err := api.WriteEvent(w, "results", update); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+
+		if err := send(&live.Update{Event: event, Changed: []model.Fight{}}); err != nil {
+			logs.Logger(ctx).Info("stream closed", "error", err)
+			return nil
+		}
+		if event.IsFinished() {
+			// results are final, nothing more will be sent
+			return nil
+		}
+
+		logs.Logger(ctx).Info("streaming event results", "event ID", eventId)
+
+		keepAlive := time.NewTicker(streamKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case update, ok := <-updates:
+				if !ok {
+					return nil
+				}
+				if err := send(update); err != nil {
+					logs.Logger(ctx).Info("stream closed", "error", err)
+					return nil
+				}
+				if update.Event.IsFinished() {
+					return nil
+				}
+			case <-keepAlive.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return nil
+				}
+				if err := rc.Flush(); err != nil {
+					return nil
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package events
+
+import (
+	"context"
+
+	"github.com/thebenkogan/ufc/internal/model"
+	"github.com/thebenkogan/ufc/internal/odds"
+	"github.com/thebenkogan/ufc/internal/util/logs"
+)
+
+// OddsEventScraper attaches moneyline odds to the fights of scraped events.
+// Until picks lock the odds are live and snapshotted on every scrape,
+// afterwards the last snapshot is used so scoring sees the odds users picked against.
+type OddsEventScraper struct {
+	scraper   EventScraper
+	provider  odds.Provider
+	snapshots odds.SnapshotRepository
+}
+
+func NewOddsEventScraper(scraper EventScraper, provider odds.Provider, snapshots odds.SnapshotRepository) *OddsEventScraper {
+	return &OddsEventScraper{
+		scraper:   scraper,
+		provider:  provider,
+		snapshots: snapshots,
+	}
+}
+
+func (s *OddsEventScraper) ScrapeEvent(ctx context.Context, league, id string) (*model.Event, error) {
+	event, err := s.scraper.ScrapeEvent(ctx, league, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !event.HasStarted() {
+		lines, err := s.provider.FetchMoneylines(ctx, league)
+		if err == nil {
+			attachOdds(event, lines)
+			if snapshot := eventOdds(event); len(snapshot) > 0 {
+				if err := s.snapshots.SaveOdds(ctx, event.Id, snapshot); err != nil {
+					logs.Logger(ctx).Warn("failed to snapshot odds", "event ID", event.Id, "error", err)
+				}
+			}
+			return event, nil
+		}
+		logs.Logger(ctx).Warn("failed to fetch odds, using last snapshot", "event ID", event.Id, "error", err)
+	}
+
+	snapshot, err := s.snapshots.GetOdds(ctx, event.Id)
+	if err != nil {
+		logs.Logger(ctx).Warn("failed to get odds snapshot", "event ID", event.Id, "error", err)
+		return event, nil
+	}
+	attachOdds(event, snapshot)
+
+	return event, nil
+}
+
+func (s *OddsEventScraper) ScrapeSchedule(ctx context.Context, league string) ([]*model.EventInfo, error) {
+	return s.scraper.ScrapeSchedule(ctx, league)
+}
+
+// Sets the odds on every fight where both fighters have a line, matching names loosely
+func attachOdds(event *model.Event, lines map[string]int) {
+	normalized := make(map[string]int, len(lines))
+	for name, price := range lines {
+		normalized[normalizeFighter(name)] = price
+	}
+
+	for i := range event.Fights {
+		fight := &event.Fights[i]
+		fightOdds := make(map[string]int, len(fight.Fighters))
+		for _, fighter := range fight.Fighters {
+			if price, ok := normalized[normalizeFighter(fighter)]; ok {
+				fightOdds[fighter] = price
+			}
+		}
+		if len(fightOdds) == len(fight.Fighters) && len(fightOdds) > 0 {
+			fight.Odds = fightOdds
+		}
+	}
+}
+
+// Returns the odds of every fighter on the card
+func eventOdds(event *model.Event) map[string]int {
+	all := make(map[string]int)
+	for _, fight := range event.Fights {
+		for fighter, price := range fight.Odds {
+			all[fighter] = price
+		}
+	}
+	return all
+}
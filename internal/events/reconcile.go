@@ -0,0 +1,159 @@
+package events
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/thebenkogan/ufc/internal/model"
+	"github.com/thebenkogan/ufc/internal/resolutions"
+	"github.com/thebenkogan/ufc/internal/util/logs"
+)
+
+// ReconcilingEventScraper scrapes events from a primary and a secondary source
+// and only reports a fight winner when both sources agree on it, or when an admin
+// has resolved the fight. A winner only the primary reports, because the secondary is
+// unavailable or lagging behind, is kept but unconfirmed. The card and event details
+// always come from the primary.
+type ReconcilingEventScraper struct {
+	primary     EventScraper
+	secondary   ResultSource
+	resolutions resolutions.FightResolutionRepository
+}
+
+// ResultSource reports the fight results of an event scraped from another source
+type ResultSource interface {
+	ScrapeResults(ctx context.Context, event *model.Event) (*model.Event, error)
+}
+
+// LeagueResultSource reads results from the source of the event's league, or the fallback
+// for leagues without one
+type LeagueResultSource struct {
+	sources  map[string]ResultSource
+	fallback ResultSource
+}
+
+func NewLeagueResultSource(sources map[string]ResultSource, fallback ResultSource) *LeagueResultSource {
+	return &LeagueResultSource{sources: sources, fallback: fallback}
+}
+
+func (s *LeagueResultSource) ScrapeResults(ctx context.Context, event *model.Event) (*model.Event, error) {
+	if source, ok := s.sources[event.League]; ok {
+		return source.ScrapeResults(ctx, event)
+	}
+	return s.fallback.ScrapeResults(ctx, event)
+}
+
+func NewReconcilingEventScraper(primary EventScraper, secondary ResultSource, resolutions resolutions.FightResolutionRepository) *ReconcilingEventScraper {
+	return &ReconcilingEventScraper{
+		primary:     primary,
+		secondary:   secondary,
+		resolutions: resolutions,
+	}
+}
+
+func (s *ReconcilingEventScraper) ScrapeEvent(ctx context.Context, league, id string) (*model.Event, error) {
+	event, err := s.primary.ScrapeEvent(ctx, league, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// scrape the resolved event so both sources describe the same event when asked for the latest
+	other, err := s.secondary.ScrapeResults(ctx, event)
+	if err != nil {
+		logs.Logger(ctx).Warn("failed to scrape secondary source, results are unconfirmed", "event ID", event.Id, "error", err)
+		other = nil
+	}
+
+	resolved, err := s.resolutions.GetResolutions(ctx, event.Id)
+	if err != nil {
+		logs.Logger(ctx).Warn("failed to get fight resolutions", "event ID", event.Id, "error", err)
+	}
+
+	for _, fight := range reconcileResults(event, other, resolved) {
+		logs.Logger(ctx).Warn("sources disagree on fight result", "event ID", event.Id, "fighters", fight.Fighters)
+	}
+
+	return event, nil
+}
+
+func (s *ReconcilingEventScraper) ScrapeSchedule(ctx context.Context, league string) ([]*model.EventInfo, error) {
+	return s.primary.ScrapeSchedule(ctx, league)
+}
+
+// normalizes fighter names so the same fighter matches across sources
+func normalizeFighter(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+// key identifying a fight independent of fighter order and name formatting
+func fightKey(fighters []string) string {
+	names := make([]string, 0, len(fighters))
+	for _, f := range fighters {
+		names = append(names, normalizeFighter(f))
+	}
+	slices.Sort(names)
+	return strings.Join(names, "|")
+}
+
+// Updates the winners in event so a fight is only decided when other reports the same
+// winner or it has been resolved. Returns the fights where the sources disagree.
+// A winner that other does not report yet, or at all when other is nil because the
+// second source is unavailable, is kept but marked unconfirmed until it is checked again.
+func reconcileResults(event *model.Event, other *model.Event, resolved []*resolutions.Resolution) []model.Fight {
+	resolvedWinners := make(map[string]string, len(resolved))
+	for _, r := range resolved {
+		resolvedWinners[fightKey(r.Fighters)] = r.Winner
+	}
+	otherFights := make(map[string]model.Fight)
+	if other != nil {
+		for _, fight := range other.Fights {
+			otherFights[fightKey(fight.Fighters)] = fight
+		}
+	}
+
+	disputed := make([]model.Fight, 0)
+	for i := range event.Fights {
+		fight := &event.Fights[i]
+		key := fightKey(fight.Fighters)
+		fight.Disputed = false
+		fight.Unconfirmed = false
+
+		if winner, ok := resolvedWinners[key]; ok {
+			// use the primary's spelling of the resolved winner
+			for _, f := range fight.Fighters {
+				if normalizeFighter(f) == normalizeFighter(winner) {
+					fight.Winner = f
+				}
+			}
+			continue
+		}
+
+		if other == nil {
+			fight.Unconfirmed = fight.Winner != ""
+			continue
+		}
+
+		otherFight := otherFights[key]
+		switch {
+		case otherFight.NoWinner && fight.Winner == "":
+			// the primary cannot tell a draw or no contest from a fight without a result yet
+			fight.NoWinner = true
+		case otherFight.NoWinner:
+			fight.Winner = ""
+			fight.Disputed = true
+			disputed = append(disputed, *fight)
+		case fight.Winner == "":
+			// no result from the primary yet
+		case otherFight.Winner == "":
+			// not confirmed yet, the other source may be lagging behind
+			fight.Unconfirmed = true
+		case normalizeFighter(otherFight.Winner) != normalizeFighter(fight.Winner):
+			fight.Winner = ""
+			fight.Disputed = true
+			disputed = append(disputed, *fight)
+		}
+	}
+
+	return disputed
+}
@@ -0,0 +1,188 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/thebenkogan/ufc/internal/cache"
+	"github.com/thebenkogan/ufc/internal/model"
+	"github.com/thebenkogan/ufc/internal/notify"
+	"github.com/thebenkogan/ufc/internal/picks"
+	"github.com/thebenkogan/ufc/internal/util/logs"
+)
+
+const (
+	reminderInterval = 15 * time.Minute
+	// schedule dates are the day of the event at local midnight, without its start time,
+	// so events are looked up a day further than any reminder, and a day past their date
+	reminderLookahead  = notify.MaxHoursBefore*time.Hour + 24*time.Hour
+	reminderLookbehind = 24 * time.Hour
+)
+
+// PickReminder reminds users who opted in to make picks for upcoming events they have not picked,
+// the number of hours before the event starts that they chose
+type PickReminder struct {
+	eventScraper EventScraper
+	eventCache   cache.EventCacheRepository
+	eventPicks   picks.EventPicksRepository
+	preferences  notify.PreferencesRepository
+	channels     map[string]notify.Channel
+	leagues      []string
+}
+
+func NewPickReminder(eventScraper EventScraper, eventCache cache.EventCacheRepository, eventPicks picks.EventPicksRepository, preferences notify.PreferencesRepository, channels []notify.Channel, leagues []string) *PickReminder {
+	byName := make(map[string]notify.Channel, len(channels))
+	for _, c := range channels {
+		byName[c.Name()] = c
+	}
+	return &PickReminder{
+		eventScraper: eventScraper,
+		eventCache:   eventCache,
+		eventPicks:   eventPicks,
+		preferences:  preferences,
+		channels:     byName,
+		leagues:      leagues,
+	}
+}
+
+// Run sends due reminders until ctx is done
+func (p *PickReminder) Run(ctx context.Context) {
+	p.remind(ctx)
+
+	ticker := time.NewTicker(reminderInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.remind(ctx)
+		}
+	}
+}
+
+func (p *PickReminder) remind(ctx context.Context) {
+	recipients, err := p.preferences.GetReminderRecipients(ctx)
+	if err != nil {
+		logs.Logger(ctx).Warn("failed to get reminder recipients", "error", err)
+		return
+	}
+	if len(recipients) == 0 {
+		return
+	}
+
+	for _, league := range p.leagues {
+		events, err := p.upcomingEvents(ctx, league)
+		if err != nil {
+			logs.Logger(ctx).Warn("failed to get upcoming events for reminders", "league", league, "error", err)
+			continue
+		}
+		for _, event := range events {
+			if err := p.remindEvent(ctx, event, recipients); err != nil {
+				logs.Logger(ctx).Warn("failed to send reminders", "event ID", event.Id, "error", err)
+			}
+		}
+	}
+}
+
+// Returns the events of the league that have not started and could be due a reminder
+func (p *PickReminder) upcomingEvents(ctx context.Context, league string) ([]*model.Event, error) {
+	schedule, err := getScheduleWithCache(ctx, p.eventScraper, p.eventCache, league)
+	if err != nil {
+		return nil, err
+	}
+
+	// the schedule is sorted by date, only events near now are loaded for their start time
+	now := time.Now()
+	events := make([]*model.Event, 0)
+	for _, info := range schedule {
+		if info.Date.Before(now.Add(-reminderLookbehind)) {
+			continue
+		}
+		if info.Date.After(now.Add(reminderLookahead)) {
+			break
+		}
+		event, err := getEventWithCache(ctx, p.eventScraper, p.eventCache, league, info.Id)
+		if err != nil {
+			return nil, err
+		}
+		if !event.HasStarted() {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (p *PickReminder) remindEvent(ctx context.Context, event *model.Event, recipients []*notify.Preferences) error {
+	startTime, err := time.Parse(time.RFC3339, event.StartTime)
+	if err != nil {
+		return err
+	}
+
+	due := make([]*notify.Preferences, 0)
+	for _, r := range recipients {
+		if time.Now().Add(time.Duration(r.HoursBefore) * time.Hour).After(startTime) {
+			due = append(due, r)
+		}
+	}
+	if len(due) == 0 {
+		return nil
+	}
+
+	eventPicks, err := p.eventPicks.GetPicksByFilter(ctx, &picks.PicksFilter{EventIDs: []string{event.Id}})
+	if err != nil {
+		return err
+	}
+	picked := make(map[string]bool, len(eventPicks))
+	for _, ep := range eventPicks {
+		picked[ep.UserId] = len(ep.Winners) > 0
+	}
+
+	msg := reminderMessage(event, startTime)
+	for _, r := range due {
+		if picked[r.UserId] {
+			continue
+		}
+		for _, name := range r.Channels {
+			p.send(ctx, event, r, name, msg)
+		}
+	}
+	return nil
+}
+
+// Sends the reminder over the channel unless it was already sent
+func (p *PickReminder) send(ctx context.Context, event *model.Event, r *notify.Preferences, name string, msg *notify.Message) {
+	channel, ok := p.channels[name]
+	if !ok {
+		return
+	}
+
+	claimed, err := p.preferences.ClaimReminder(ctx, r.UserId, event.Id, name)
+	if err != nil || !claimed {
+		if err != nil {
+			logs.Logger(ctx).Warn("failed to claim reminder", "user ID", r.UserId, "event ID", event.Id, "error", err)
+		}
+		return
+	}
+
+	logs.Logger(ctx).Info("sending pick reminder", "user ID", r.UserId, "event ID", event.Id, "channel", name)
+
+	if err := channel.Send(ctx, &notify.Recipient{UserId: r.UserId, Email: r.Email}, msg); err != nil {
+		logs.Logger(ctx).Warn("failed to send reminder", "user ID", r.UserId, "event ID", event.Id, "channel", name, "error", err)
+		if err := p.preferences.ReleaseReminder(ctx, r.UserId, event.Id, name); err != nil {
+			logs.Logger(ctx).Warn("failed to release reminder", "user ID", r.UserId, "event ID", event.Id, "error", err)
+		}
+	}
+}
+
+func reminderMessage(event *model.Event, startTime time.Time) *notify.Message {
+	return &notify.Message{
+		Subject: fmt.Sprintf("Make your picks for %s", event.Name),
+		Body: fmt.Sprintf(
+			"%s starts %s and you haven't made your picks yet.\nPicks close when the event starts.\n",
+			event.Name,
+			startTime.UTC().Format("Mon, Jan 2 at 3:04 PM MST"),
+		),
+	}
+}
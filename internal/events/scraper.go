@@ -1,6 +1,7 @@
 package events
 
 import (
+	"context"
 	"fmt"
 	"slices"
 	"strings"
@@ -11,8 +12,8 @@ import (
 )
 
 type EventScraper interface {
-	ScrapeEvent(id string) (*model.Event, error)
-	ScrapeSchedule() ([]*model.EventInfo, error)
+	ScrapeEvent(ctx context.Context, league, id string) (*model.Event, error)
+	ScrapeSchedule(ctx context.Context, league string) ([]*model.EventInfo, error)
 }
 
 type ESPNEventScraper struct{}
@@ -21,15 +22,15 @@ func NewESPNEventScraper() *ESPNEventScraper {
 	return &ESPNEventScraper{}
 }
 
-func (_ ESPNEventScraper) makeUrl(id string) string {
+func (_ ESPNEventScraper) makeUrl(league, id string) string {
 	if id == eventLatest {
-		return "https://www.espn.com/mma/fightcenter"
+		return fmt.Sprintf("https://www.espn.com/mma/fightcenter/_/league/%s", league)
 	}
-	return fmt.Sprintf("https://www.espn.com/mma/fightcenter/_/id/%s/league/ufc", id)
+	return fmt.Sprintf("https://www.espn.com/mma/fightcenter/_/id/%s/league/%s", id, league)
 }
 
-func (e ESPNEventScraper) ScrapeEvent(id string) (*model.Event, error) {
-	event := model.Event{Fights: make([]model.Fight, 0)}
+func (e ESPNEventScraper) ScrapeEvent(_ context.Context, league, id string) (*model.Event, error) {
+	event := model.Event{League: league, Fights: make([]model.Fight, 0)}
 	var eventDate string
 	var earliestTime string
 
@@ -77,7 +78,7 @@ func (e ESPNEventScraper) ScrapeEvent(id string) (*model.Event, error) {
 		})
 	})
 
-	if err := c.Visit(e.makeUrl(id)); err != nil {
+	if err := c.Visit(e.makeUrl(league, id)); err != nil {
 		return nil, fmt.Errorf("failed to visit URL: %v", err)
 	}
 	c.Wait()
@@ -98,11 +99,27 @@ func (e ESPNEventScraper) ScrapeEvent(id string) (*model.Event, error) {
 	return &event, nil
 }
 
-func (_ ESPNEventScraper) scheduleURL() string {
-	return "https://www.espn.com/mma/schedule/_/league/ufc"
+func (_ ESPNEventScraper) scheduleURL(league string) string {
+	return fmt.Sprintf("https://www.espn.com/mma/schedule/_/league/%s", league)
 }
 
-func (e ESPNEventScraper) ScrapeSchedule() ([]*model.EventInfo, error) {
+// schedule of events already held in the given year
+func (_ ESPNEventScraper) pastScheduleURL(league string, year int) string {
+	return fmt.Sprintf("https://www.espn.com/mma/schedule/_/year/%d/league/%s", year, league)
+}
+
+func (e ESPNEventScraper) ScrapeSchedule(_ context.Context, league string) ([]*model.EventInfo, error) {
+	return e.scrapeScheduleTable(e.scheduleURL(league), league, 0)
+}
+
+// Scrapes the events listed for a past year, sorted by date
+func (e ESPNEventScraper) ScrapePastEvents(_ context.Context, league string, year int) ([]*model.EventInfo, error) {
+	return e.scrapeScheduleTable(e.pastScheduleURL(league, year), league, year)
+}
+
+// Scrapes the events in a schedule table. The table only lists month and day,
+// so year is applied to the parsed dates, or the nearest year when it is 0.
+func (_ ESPNEventScraper) scrapeScheduleTable(url, league string, year int) ([]*model.EventInfo, error) {
 	events := make([]*model.EventInfo, 0)
 
 	c := colly.NewCollector()
@@ -115,16 +132,14 @@ func (e ESPNEventScraper) ScrapeSchedule() ([]*model.EventInfo, error) {
 			return
 		}
 		id := strings.Split(link, "/")[5]
-		loc, _ := time.LoadLocation("Local")
-		layout := "Jan 2"
-		t, err := time.ParseInLocation(layout, date, loc)
+		t, err := parseScheduleDate(date, year, time.Now())
 		if err != nil {
 			return
 		}
-		events = append(events, &model.EventInfo{Id: id, Name: name, Date: t})
+		events = append(events, &model.EventInfo{Id: id, League: league, Name: name, Date: t})
 	})
 
-	if err := c.Visit(e.scheduleURL()); err != nil {
+	if err := c.Visit(url); err != nil {
 		return nil, fmt.Errorf("failed to visit URL: %v", err)
 	}
 	c.Wait()
@@ -135,3 +150,27 @@ func (e ESPNEventScraper) ScrapeSchedule() ([]*model.EventInfo, error) {
 
 	return events, nil
 }
+
+// Parses a month and day from a schedule table in year, or when year is 0, in the year
+// that puts the date closest to now. Upcoming schedules span the turn of the year.
+func parseScheduleDate(date string, year int, now time.Time) (time.Time, error) {
+	loc, _ := time.LoadLocation("Local")
+	t, err := time.ParseInLocation("Jan 2", date, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	inYear := func(year int) time.Time {
+		return time.Date(year, t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	}
+	if year != 0 {
+		return inYear(year), nil
+	}
+
+	nearest := inYear(now.Year())
+	for _, y := range []int{now.Year() - 1, now.Year() + 1} {
+		if d := inYear(y); d.Sub(now).Abs() < nearest.Sub(now).Abs() {
+			nearest = d
+		}
+	}
+	return nearest, nil
+}
@@ -0,0 +1,118 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gocolly/colly"
+	"github.com/thebenkogan/ufc/internal/model"
+)
+
+// UFCStatsResultSource reads UFC fight results from ufcstats.com, which is run
+// independently of ESPN. It has its own event IDs, so events are matched to the
+// primary's by date, and fights are matched by fighter names during reconciliation.
+type UFCStatsResultSource struct {
+	baseURL string
+}
+
+func NewUFCStatsResultSource() *UFCStatsResultSource {
+	return &UFCStatsResultSource{baseURL: "http://ufcstats.com"}
+}
+
+// the most an event's date on ufcstats may be away from the primary's start time
+const ufcStatsMaxDateDistance = 36 * time.Hour
+
+type ufcStatsEvent struct {
+	url  string
+	date time.Time
+}
+
+func (s UFCStatsResultSource) ScrapeResults(_ context.Context, event *model.Event) (*model.Event, error) {
+	start := time.Now()
+	if event.StartTime != "LIVE" {
+		t, err := time.Parse(time.RFC3339, event.StartTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start time %q: %w", event.StartTime, err)
+		}
+		start = t
+	}
+
+	events, err := s.scrapeEvents()
+	if err != nil {
+		return nil, err
+	}
+	var match *ufcStatsEvent
+	for _, e := range events {
+		if d := e.date.Sub(start).Abs(); d <= ufcStatsMaxDateDistance && (match == nil || d < match.date.Sub(start).Abs()) {
+			match = &e
+		}
+	}
+	if match == nil {
+		return nil, fmt.Errorf("no event on ufcstats near %s", start.Format(time.DateOnly))
+	}
+
+	fights, err := s.scrapeFights(match.url)
+	if err != nil {
+		return nil, err
+	}
+	return &model.Event{Id: event.Id, League: event.League, Name: event.Name, StartTime: event.StartTime, Fights: fights}, nil
+}
+
+// Scrapes the recent and upcoming events, a live event is listed with either
+func (s UFCStatsResultSource) scrapeEvents() ([]ufcStatsEvent, error) {
+	events := make([]ufcStatsEvent, 0)
+
+	c := colly.NewCollector()
+
+	c.OnHTML("tr.b-statistics__table-row", func(e *colly.HTMLElement) {
+		url := e.ChildAttr("a.b-link", "href")
+		date, err := time.Parse("January 2, 2006", strings.TrimSpace(e.ChildText("span.b-statistics__date")))
+		if url == "" || err != nil {
+			return
+		}
+		events = append(events, ufcStatsEvent{url: url, date: date})
+	})
+
+	for _, list := range []string{"completed", "upcoming"} {
+		if err := c.Visit(fmt.Sprintf("%s/statistics/events/%s", s.baseURL, list)); err != nil {
+			return nil, fmt.Errorf("failed to visit URL: %v", err)
+		}
+	}
+	c.Wait()
+
+	return events, nil
+}
+
+func (_ UFCStatsResultSource) scrapeFights(url string) ([]model.Fight, error) {
+	fights := make([]model.Fight, 0)
+
+	c := colly.NewCollector()
+
+	c.OnHTML("tr.b-fight-details__table-row", func(e *colly.HTMLElement) {
+		fighters := make([]string, 0)
+		e.ForEach("td:nth-child(2) a", func(_ int, el *colly.HTMLElement) {
+			fighters = append(fighters, strings.TrimSpace(el.Text))
+		})
+		if len(fighters) == 0 {
+			return
+		}
+		fight := model.Fight{Fighters: fighters}
+		// the winner is listed first, both fighters are flagged for a draw or no contest
+		switch strings.TrimSpace(e.DOM.Find("i.b-flag__text").First().Text()) {
+		case "win":
+			fight.Winner = fighters[0]
+		case "draw", "nc":
+			fight.NoWinner = true
+		}
+		fights = append(fights, fight)
+	})
+
+	if err := c.Visit(url); err != nil {
+		return nil, fmt.Errorf("failed to visit URL: %v", err)
+	}
+	c.Wait()
+
+	return fights, nil
+}
@@ -0,0 +1,81 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/thebenkogan/ufc/internal/cache"
+	"github.com/thebenkogan/ufc/internal/util/logs"
+)
+
+const (
+	// shorter than scheduleTTL so the schedule never expires between warms
+	warmScheduleInterval = scheduleTTL / 2
+	// number of upcoming events per league to keep warm
+	warmUpcomingEvents = 3
+)
+
+// CacheWarmer keeps the schedule, the next few events and the latest event of each league
+// in the cache, so user requests virtually never scrape. The latest event is polled at
+// duringFreshTime so a live card is refreshed before it goes stale.
+type CacheWarmer struct {
+	eventScraper EventScraper
+	eventCache   cache.EventCacheRepository
+	leagues      []string
+}
+
+func NewCacheWarmer(eventScraper EventScraper, eventCache cache.EventCacheRepository, leagues []string) *CacheWarmer {
+	return &CacheWarmer{
+		eventScraper: eventScraper,
+		eventCache:   eventCache,
+		leagues:      leagues,
+	}
+}
+
+// Run warms the cache until ctx is done
+func (w *CacheWarmer) Run(ctx context.Context) {
+	w.warmSchedules(ctx)
+	w.warmLatest(ctx)
+
+	scheduleTicker := time.NewTicker(warmScheduleInterval)
+	defer scheduleTicker.Stop()
+	latestTicker := time.NewTicker(duringFreshTime)
+	defer latestTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-scheduleTicker.C:
+			w.warmSchedules(ctx)
+		case <-latestTicker.C:
+			w.warmLatest(ctx)
+		}
+	}
+}
+
+// Scrapes the schedule of every league and the first upcoming events on it
+func (w *CacheWarmer) warmSchedules(ctx context.Context) {
+	for _, league := range w.leagues {
+		schedule, err := scrapeAndCacheSchedule(ctx, w.eventScraper, w.eventCache, league)
+		if err != nil {
+			logs.Logger(ctx).Warn("failed to warm schedule", "league", league, "error", err)
+			continue
+		}
+
+		for _, info := range schedule[:min(len(schedule), warmUpcomingEvents)] {
+			if _, err := scrapeAndCacheEventOnce(ctx, w.eventScraper, w.eventCache, league, info.Id, warmScheduleInterval); err != nil {
+				logs.Logger(ctx).Warn("failed to warm event", "league", league, "event ID", info.Id, "error", err)
+			}
+		}
+	}
+}
+
+// Refreshes the latest event of every league if it would go stale before the next poll
+func (w *CacheWarmer) warmLatest(ctx context.Context) {
+	for _, league := range w.leagues {
+		if _, err := scrapeAndCacheEventOnce(ctx, w.eventScraper, w.eventCache, league, eventLatest, duringFreshTime); err != nil {
+			logs.Logger(ctx).Warn("failed to warm latest event", "league", league, "error", err)
+		}
+	}
+}
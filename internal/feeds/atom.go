@@ -0,0 +1,76 @@
+package feeds
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// Feed is an RFC 4287 Atom feed
+type Feed struct {
+	XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Updated string   `xml:"updated"`
+	Link    *Link    `xml:"link,omitempty"`
+	Author  Author   `xml:"author"`
+	Entries []*Entry `xml:"entry"`
+}
+
+type Link struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type Author struct {
+	Name string `xml:"name"`
+}
+
+type Entry struct {
+	ID      string  `xml:"id"`
+	Title   string  `xml:"title"`
+	Updated string  `xml:"updated"`
+	Content Content `xml:"content"`
+}
+
+type Content struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// NewFeed creates a feed updated when its newest entry was
+func NewFeed(id, title, selfURL string, entries []*Entry) *Feed {
+	updated := time.Unix(0, 0).UTC().Format(time.RFC3339)
+	for _, e := range entries {
+		if e.Updated > updated {
+			updated = e.Updated
+		}
+	}
+	return &Feed{
+		ID:      id,
+		Title:   title,
+		Updated: updated,
+		Link:    &Link{Rel: "self", Href: selfURL},
+		Author:  Author{Name: "UFC Picks"},
+		Entries: entries,
+	}
+}
+
+// NewEntry creates an entry with plain text content
+func NewEntry(id, title string, updated time.Time, content string) *Entry {
+	return &Entry{
+		ID:      id,
+		Title:   title,
+		Updated: updated.UTC().Format(time.RFC3339),
+		Content: Content{Type: "text", Body: content},
+	}
+}
+
+func WriteFeed(w io.Writer, feed *Feed) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(feed)
+}
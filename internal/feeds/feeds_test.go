@@ -0,0 +1,162 @@
+package feeds
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thebenkogan/ufc/internal/auth"
+	"github.com/thebenkogan/ufc/internal/util/api"
+)
+
+func TestFormatDuration(t *testing.T) {
+	durationTests := []struct {
+		d        time.Duration
+		expected string
+	}{
+		{-time.Hour, "-PT1H"},
+		{-90 * time.Minute, "-PT1H30M"},
+		{24 * time.Hour, "PT24H"},
+		{45 * time.Second, "PT45S"},
+		{0, "PT0S"},
+	}
+
+	for _, tt := range durationTests {
+		t.Run(fmt.Sprintf("%v", tt.d), func(t *testing.T) {
+			assert.Equal(t, tt.expected, formatDuration(tt.d))
+		})
+	}
+}
+
+func TestFoldLine(t *testing.T) {
+	assert.Equal(t, "short", foldLine("short"))
+
+	line := "DESCRIPTION:" + strings.Repeat("é", 60)
+	folded := foldLine(line)
+	parts := strings.Split(folded, "\r\n ")
+	require.Len(t, parts, 2)
+	for _, part := range parts {
+		assert.LessOrEqual(t, len(part), maxLineOctets)
+	}
+	// no character is split across lines
+	assert.Equal(t, line, strings.Join(parts, ""))
+	assert.Equal(t, 0, strings.Count(folded, "�"))
+}
+
+func TestWriteCalendar(t *testing.T) {
+	var b strings.Builder
+	err := WriteCalendar(&b, "UFC schedule", []CalendarEvent{{
+		UID:         "ufc-1@ufc-picks",
+		Summary:     "UFC 300: Pereira vs. Hill",
+		Description: "Picks close when the event starts.",
+		Start:       time.Date(2024, 4, 13, 22, 0, 0, 0, time.UTC),
+		Duration:    6 * time.Hour,
+		Alarms:      []time.Duration{time.Hour},
+	}})
+	require.NoError(t, err)
+
+	cal := b.String()
+	assert.True(t, strings.HasPrefix(cal, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	assert.True(t, strings.HasSuffix(cal, "END:VEVENT\r\nEND:VCALENDAR\r\n"))
+	assert.Contains(t, cal, "\r\nUID:ufc-1@ufc-picks\r\n")
+	assert.Contains(t, cal, "\r\nDTSTART:20240413T220000Z\r\nDTEND:20240414T040000Z\r\n")
+	assert.Contains(t, cal, "\r\nSUMMARY:UFC 300: Pereira vs. Hill\r\n")
+	assert.Contains(t, cal, "\r\nBEGIN:VALARM\r\nACTION:DISPLAY\r\nTRIGGER:-PT1H\r\n")
+	assert.NotContains(t, strings.ReplaceAll(cal, "\r\n", ""), "\n")
+}
+
+func TestEscapeText(t *testing.T) {
+	assert.Equal(t, `Pereira\, Hill\; main card\nline \\ two`, escapeText("Pereira, Hill; main card\nline \\ two"))
+}
+
+func TestWriteFeed(t *testing.T) {
+	older := NewEntry("urn:ufc-picks:results:1", "UFC 299", time.Date(2024, 3, 10, 5, 0, 0, 0, time.UTC), "A def. B")
+	newer := NewEntry("urn:ufc-picks:results:2", "UFC 300", time.Date(2024, 4, 14, 5, 0, 0, 0, time.UTC), "C def. D\nE & F: no result")
+	feed := NewFeed("urn:ufc-picks:results:ufc", "UFC results", "http://localhost/results.atom", []*Entry{newer, older})
+	assert.Equal(t, "2024-04-14T05:00:00Z", feed.Updated)
+
+	var b strings.Builder
+	require.NoError(t, WriteFeed(&b, feed))
+
+	atom := b.String()
+	assert.True(t, strings.HasPrefix(atom, `<?xml version="1.0" encoding="UTF-8"?>`))
+	assert.Contains(t, atom, `<feed xmlns="http://www.w3.org/2005/Atom">`)
+	assert.Contains(t, atom, `<link rel="self" href="http://localhost/results.atom"></link>`)
+	assert.Contains(t, atom, `<title>UFC 300</title>`)
+	assert.Contains(t, atom, `<content type="text">C def. D&#xA;E &amp; F: no result</content>`)
+}
+
+// testFeedTokens stores token hashes in memory like PostgresFeedTokens
+type testFeedTokens struct {
+	hashes map[string]string
+}
+
+func (s *testFeedTokens) CreateToken(ctx context.Context, userId string) (string, error) {
+	if _, ok := s.hashes[userId]; ok {
+		return "", nil
+	}
+	return s.RotateToken(ctx, userId)
+}
+
+func (s *testFeedTokens) RotateToken(_ context.Context, userId string) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	s.hashes[userId] = hashToken(token)
+	return token, nil
+}
+
+func (s *testFeedTokens) GetUserId(_ context.Context, token string) (string, error) {
+	for userId, hash := range s.hashes {
+		if hash == hashToken(token) {
+			return userId, nil
+		}
+	}
+	return "", nil
+}
+
+func TestHandleFeeds(t *testing.T) {
+	ctx := auth.WithUser(context.Background(), &auth.User{Id: "123"})
+	tokens := &testFeedTokens{hashes: make(map[string]string)}
+
+	request := func(h api.Handler) (int, FeedURLs) {
+		r := httptest.NewRequest(http.MethodGet, "http://localhost/me/feeds", nil)
+		w := httptest.NewRecorder()
+		require.NoError(t, h(ctx, w, r))
+		var urls FeedURLs
+		_ = json.NewDecoder(w.Body).Decode(&urls)
+		return w.Code, urls
+	}
+	tokenOf := func(urls FeedURLs) string {
+		return strings.Split(strings.TrimPrefix(urls.Calendar, "http://localhost/feeds/"), "/")[0]
+	}
+
+	code, urls := request(HandleGetFeeds(tokens))
+	require.Equal(t, http.StatusOK, code)
+	issued := tokenOf(urls)
+	assert.NotEqual(t, issued, tokens.hashes["123"], "only the hash should be stored")
+	userId, err := tokens.GetUserId(ctx, issued)
+	require.NoError(t, err)
+	assert.Equal(t, "123", userId)
+
+	// the URLs are only shown when the token is issued
+	code, _ = request(HandleGetFeeds(tokens))
+	assert.Equal(t, http.StatusConflict, code)
+
+	code, urls = request(HandleRotateFeeds(tokens))
+	require.Equal(t, http.StatusOK, code)
+	userId, err = tokens.GetUserId(ctx, issued)
+	require.NoError(t, err)
+	assert.Empty(t, userId, "rotating should invalidate the old token")
+	userId, err = tokens.GetUserId(ctx, tokenOf(urls))
+	require.NoError(t, err)
+	assert.Equal(t, "123", userId)
+}
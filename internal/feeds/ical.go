@@ -0,0 +1,123 @@
+package feeds
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// CalendarEvent is a VEVENT of an RFC 5545 calendar
+type CalendarEvent struct {
+	// globally unique and stable across feed refreshes, so clients update instead of duplicating
+	UID         string
+	Summary     string
+	Description string
+	Start       time.Time
+	Duration    time.Duration
+	// display alarms, each triggered this long before the start
+	Alarms []time.Duration
+}
+
+const icalTimeFormat = "20060102T150405Z"
+
+// WriteCalendar writes the events as an RFC 5545 calendar named name
+func WriteCalendar(w io.Writer, name string, events []CalendarEvent) error {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//ufc-picks//schedule//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"X-WR-CALNAME:" + escapeText(name),
+	}
+
+	stamp := time.Now().UTC().Format(icalTimeFormat)
+	for _, e := range events {
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			"UID:"+escapeText(e.UID),
+			"DTSTAMP:"+stamp,
+			"DTSTART:"+e.Start.UTC().Format(icalTimeFormat),
+			"DTEND:"+e.Start.Add(e.Duration).UTC().Format(icalTimeFormat),
+			"SUMMARY:"+escapeText(e.Summary),
+		)
+		if e.Description != "" {
+			lines = append(lines, "DESCRIPTION:"+escapeText(e.Description))
+		}
+		for _, before := range e.Alarms {
+			lines = append(lines,
+				"BEGIN:VALARM",
+				"ACTION:DISPLAY",
+				"TRIGGER:"+formatDuration(-before),
+				"DESCRIPTION:"+escapeText(e.Summary),
+				"END:VALARM",
+			)
+		}
+		lines = append(lines, "END:VEVENT")
+	}
+	lines = append(lines, "END:VCALENDAR")
+
+	for _, line := range lines {
+		if _, err := io.WriteString(w, foldLine(line)+"\r\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// Formats d as an RFC 5545 duration, such as -PT1H30M
+func formatDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+	h := int(d.Hours())
+	m := int(d.Minutes()) % 60
+	s := int(d.Seconds()) % 60
+
+	var b strings.Builder
+	b.WriteString(sign + "PT")
+	if h > 0 {
+		fmt.Fprintf(&b, "%dH", h)
+	}
+	if m > 0 {
+		fmt.Fprintf(&b, "%dM", m)
+	}
+	if s > 0 || (h == 0 && m == 0) {
+		fmt.Fprintf(&b, "%dS", s)
+	}
+	return b.String()
+}
+
+// lines longer than 75 octets are folded with a CRLF followed by a space,
+// without splitting a UTF-8 character
+const maxLineOctets = 75
+
+func foldLine(line string) string {
+	if len(line) <= maxLineOctets {
+		return line
+	}
+	var b strings.Builder
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		// continuation lines start with the space
+		limit = maxLineOctets - 1
+	}
+	b.WriteString(line)
+	return b.String()
+}
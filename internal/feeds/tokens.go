@@ -0,0 +1,149 @@
+package feeds
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/thebenkogan/ufc/internal/auth"
+	"github.com/thebenkogan/ufc/internal/util/api"
+)
+
+// FeedTokenRepository stores the secret token in each user's feed URLs.
+// Feed readers and calendars cannot log in, so the token identifies the user instead.
+// Only a hash of each token is stored, so a token is only known when it is issued.
+type FeedTokenRepository interface {
+	// CreateToken returns a new token for the user, or "" if they already have one
+	CreateToken(ctx context.Context, userId string) (string, error)
+	// RotateToken replaces the user's token, invalidating their existing feed URLs
+	RotateToken(ctx context.Context, userId string) (string, error)
+	// GetUserId returns the user the token belongs to, or "" if it belongs to nobody
+	GetUserId(ctx context.Context, token string) (string, error)
+}
+
+type PostgresFeedTokens struct {
+	client *pgxpool.Pool
+}
+
+func NewPostgresFeedTokens(client *pgxpool.Pool) *PostgresFeedTokens {
+	return &PostgresFeedTokens{
+		client: client,
+	}
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate feed token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (p *PostgresFeedTokens) CreateToken(ctx context.Context, userId string) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	tag, err := p.client.Exec(ctx, "INSERT INTO feed_tokens (user_id, token_hash) VALUES ($1, $2) ON CONFLICT (user_id) DO NOTHING", userId, hashToken(token))
+	if err != nil {
+		return "", err
+	}
+	if tag.RowsAffected() == 0 {
+		return "", nil
+	}
+	return token, nil
+}
+
+func (p *PostgresFeedTokens) RotateToken(ctx context.Context, userId string) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	if _, err := p.client.Exec(ctx, "INSERT INTO feed_tokens (user_id, token_hash) VALUES ($1, $2) ON CONFLICT (user_id) DO UPDATE SET token_hash = EXCLUDED.token_hash, created_at = CURRENT_TIMESTAMP", userId, hashToken(token)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (p *PostgresFeedTokens) GetUserId(ctx context.Context, token string) (string, error) {
+	var userId string
+	err := p.client.QueryRow(ctx, "SELECT user_id FROM feed_tokens WHERE token_hash = $1", hashToken(token)).Scan(&userId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return userId, err
+}
+
+// FeedURLs are the personal feed URLs of a user
+type FeedURLs struct {
+	Calendar string `json:"calendar"`
+	Picks    string `json:"picks"`
+}
+
+// BaseURL returns the scheme and host the request was made to, for absolute links in feeds
+func BaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s", scheme, r.Host)
+}
+
+func feedURLs(r *http.Request, token string) FeedURLs {
+	base := BaseURL(r) + "/feeds/" + token
+	return FeedURLs{
+		Calendar: base + "/schedule.ics",
+		Picks:    base + "/picks.atom",
+	}
+}
+
+func HandleGetFeeds(tokens FeedTokenRepository) api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		user := auth.GetUser(ctx)
+		if user == nil {
+			return fmt.Errorf("no user in context")
+		}
+
+		token, err := tokens.CreateToken(ctx, user.Id)
+		if err != nil {
+			return fmt.Errorf("error creating feed token: %w", err)
+		}
+		if token == "" {
+			// only the hash of the token is stored, so its URLs cannot be shown again
+			http.Error(w, "feed URLs were already issued, rotate them to get new URLs", http.StatusConflict)
+			return nil
+		}
+
+		api.Encode(w, http.StatusOK, feedURLs(r, token))
+		return nil
+	}
+}
+
+func HandleRotateFeeds(tokens FeedTokenRepository) api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		user := auth.GetUser(ctx)
+		if user == nil {
+			return fmt.Errorf("no user in context")
+		}
+
+		token, err := tokens.RotateToken(ctx, user.Id)
+		if err != nil {
+			return fmt.Errorf("error rotating feed token: %w", err)
+		}
+
+		api.Encode(w, http.StatusOK, feedURLs(r, token))
+		return nil
+	}
+}
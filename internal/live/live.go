@@ -0,0 +1,145 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/thebenkogan/ufc/internal/model"
+	"github.com/thebenkogan/ufc/internal/util/logs"
+)
+
+// Update is a change in the results of an event
+type Update struct {
+	// the event after the change
+	Event *model.Event `json:"event"`
+	// the fights whose result changed
+	Changed []model.Fight `json:"changed"`
+	// Started is set when the event started since it was last seen, which locks picks
+	Started bool `json:"started,omitempty"`
+}
+
+// Broker fans out result updates to every subscriber of the event, on all instances
+type Broker interface {
+	Publish(ctx context.Context, update *Update) error
+	// Subscribe returns the updates of the event until ctx is done
+	Subscribe(ctx context.Context, eventId string) <-chan *Update
+}
+
+// updates are dropped for subscribers that fall this far behind
+const subscriberBuffer = 16
+
+// hub delivers updates to the subscribers in this process
+type hub struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan *Update]struct{}
+}
+
+func newHub() *hub {
+	return &hub{subscribers: make(map[string]map[chan *Update]struct{})}
+}
+
+func (h *hub) subscribe(ctx context.Context, eventId string) <-chan *Update {
+	ch := make(chan *Update, subscriberBuffer)
+
+	h.mu.Lock()
+	if h.subscribers[eventId] == nil {
+		h.subscribers[eventId] = make(map[chan *Update]struct{})
+	}
+	h.subscribers[eventId][ch] = struct{}{}
+	h.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subscribers[eventId], ch)
+		if len(h.subscribers[eventId]) == 0 {
+			delete(h.subscribers, eventId)
+		}
+		close(ch)
+	}()
+	return ch
+}
+
+func (h *hub) deliver(ctx context.Context, update *Update) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers[update.Event.Id] {
+		select {
+		case ch <- update:
+		default:
+			logs.Logger(ctx).Warn("dropped update for slow subscriber", "event ID", update.Event.Id)
+		}
+	}
+}
+
+// MemoryBroker only delivers updates within this process
+type MemoryBroker struct {
+	hub *hub
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{hub: newHub()}
+}
+
+func (m *MemoryBroker) Publish(ctx context.Context, update *Update) error {
+	m.hub.deliver(ctx, update)
+	return nil
+}
+
+func (m *MemoryBroker) Subscribe(ctx context.Context, eventId string) <-chan *Update {
+	return m.hub.subscribe(ctx, eventId)
+}
+
+const resultsChannel = "event_results"
+
+// RedisBroker publishes updates over Redis pub/sub.
+// Each instance holds a single subscription and fans updates out to its own subscribers.
+type RedisBroker struct {
+	client *redis.Client
+	hub    *hub
+}
+
+func NewRedisBroker(client *redis.Client) *RedisBroker {
+	return &RedisBroker{
+		client: client,
+		hub:    newHub(),
+	}
+}
+
+func (r *RedisBroker) Publish(ctx context.Context, update *Update) error {
+	data, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+	return r.client.Publish(ctx, resultsChannel, data).Err()
+}
+
+func (r *RedisBroker) Subscribe(ctx context.Context, eventId string) <-chan *Update {
+	return r.hub.subscribe(ctx, eventId)
+}
+
+// Run delivers updates published by any instance to the subscribers of this one until ctx is done
+func (r *RedisBroker) Run(ctx context.Context) {
+	pubsub := r.client.Subscribe(ctx, resultsChannel)
+	defer pubsub.Close()
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			var update Update
+			if err := json.Unmarshal([]byte(msg.Payload), &update); err != nil || update.Event == nil {
+				logs.Logger(ctx).Warn("failed to decode result update", "error", err)
+				continue
+			}
+			r.hub.deliver(ctx, &update)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package live
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thebenkogan/ufc/internal/model"
+)
+
+func TestMemoryBroker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	broker := NewMemoryBroker()
+
+	updates := broker.Subscribe(ctx, "1")
+	other := broker.Subscribe(ctx, "2")
+
+	update := &Update{Event: &model.Event{Id: "1"}, Changed: []model.Fight{{Fighters: []string{"A", "B"}, Winner: "A"}}}
+	assert.NoError(t, broker.Publish(ctx, update))
+
+	select {
+	case got := <-updates:
+		assert.Equal(t, update, got)
+	case <-time.After(time.Second):
+		t.Fatal("update was not delivered")
+	}
+	assert.Empty(t, other)
+
+	cancel()
+	_, open := <-updates
+	assert.False(t, open)
+}
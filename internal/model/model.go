@@ -2,9 +2,16 @@ package model
 
 import "time"
 
+// LeagueUFC is the default league for routes that do not specify one.
+const LeagueUFC = "ufc"
+
+// Leagues are the promotions supported by the ESPN fightcenter.
+var Leagues = []string{LeagueUFC, "pfl", "bellator"}
+
 type Event struct {
-	Id   string `json:"id"`
-	Name string `json:"name"`
+	Id     string `json:"id"`
+	League string `json:"league"`
+	Name   string `json:"name"`
 	// ISO formatted start time of the event.
 	// If the event is live, this is "LIVE" (due to a limitation in knowing the start time while the event is active).
 	StartTime string  `json:"start_time"`
@@ -21,7 +28,7 @@ func (e *Event) HasStarted() bool {
 
 func (e *Event) IsFinished() bool {
 	for _, fight := range e.Fights {
-		if fight.Winner == "" {
+		if !fight.IsDecided() {
 			return false
 		}
 	}
@@ -31,10 +38,42 @@ func (e *Event) IsFinished() bool {
 type Fight struct {
 	Fighters []string `json:"fighters"`
 	Winner   string   `json:"winner,omitempty"`
+	// Disputed is set when data sources report different winners for the fight.
+	// A disputed fight has no winner until the sources agree or an admin resolves it.
+	Disputed bool `json:"disputed,omitempty"`
+	// NoWinner is set when the fight ended without a winner, in a draw or no contest.
+	NoWinner bool `json:"no_winner,omitempty"`
+	// Unconfirmed is set when only one data source reports the winner.
+	// The winner is shown, but the fight is undecided until the other source confirms it.
+	Unconfirmed bool `json:"unconfirmed,omitempty"`
+	// American moneyline odds by fighter, snapshotted when picks lock
+	Odds map[string]int `json:"odds,omitempty"`
+}
+
+// Reports whether the fight has a confirmed result that picks can be scored on
+func (f *Fight) IsDecided() bool {
+	return (f.Winner != "" || f.NoWinner) && !f.Unconfirmed
+}
+
+// Returns the fighter with the longer odds, or "" if the odds are unknown or even
+func (f *Fight) Underdog() string {
+	if len(f.Fighters) != 2 {
+		return ""
+	}
+	a, okA := f.Odds[f.Fighters[0]]
+	b, okB := f.Odds[f.Fighters[1]]
+	if !okA || !okB || a == b {
+		return ""
+	}
+	if a > b {
+		return f.Fighters[0]
+	}
+	return f.Fighters[1]
 }
 
 type EventInfo struct {
-	Id   string    `json:"id"`
-	Name string    `json:"name"`
-	Date time.Time `json:"date"`
+	Id     string    `json:"id"`
+	League string    `json:"league"`
+	Name   string    `json:"name"`
+	Date   time.Time `json:"date"`
 }
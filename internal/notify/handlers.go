@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/thebenkogan/ufc/internal/auth"
+	"github.com/thebenkogan/ufc/internal/util/api"
+)
+
+func HandleGetPreferences(preferences PreferencesRepository) api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		user := auth.GetUser(ctx)
+		if user == nil {
+			return fmt.Errorf("no user in context")
+		}
+
+		prefs, err := preferences.GetPreferences(ctx, user.Id)
+		if err != nil {
+			return fmt.Errorf("error getting preferences: %w", err)
+		}
+		if prefs == nil {
+			prefs = &Preferences{UserId: user.Id, Email: user.Email, HoursBefore: DefaultHoursBefore, Channels: []string{ChannelEmail}}
+		}
+
+		api.Encode(w, http.StatusOK, prefs)
+		return nil
+	}
+}
+
+type PutPreferencesRequest struct {
+	RemindersEnabled bool     `json:"reminders_enabled"`
+	HoursBefore      int      `json:"hours_before"`
+	Channels         []string `json:"channels"`
+}
+
+var supportedChannels = []string{ChannelEmail}
+
+func HandlePutPreferences(preferences PreferencesRepository) api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		user := auth.GetUser(ctx)
+		if user == nil {
+			return fmt.Errorf("no user in context")
+		}
+
+		var req PutPreferencesRequest
+		api.Decode(r, &req)
+
+		if req.HoursBefore < 1 || req.HoursBefore > MaxHoursBefore {
+			http.Error(w, fmt.Sprintf("hours_before must be between 1 and %d", MaxHoursBefore), http.StatusBadRequest)
+			return nil
+		}
+		if req.Channels == nil {
+			req.Channels = []string{ChannelEmail}
+		}
+		for _, c := range req.Channels {
+			if !slices.Contains(supportedChannels, c) {
+				http.Error(w, fmt.Sprintf("unknown channel: %s", c), http.StatusBadRequest)
+				return nil
+			}
+		}
+
+		// the address is taken from the identity provider so users can only opt in their own
+		prefs := &Preferences{
+			UserId:           user.Id,
+			Email:            user.Email,
+			RemindersEnabled: req.RemindersEnabled,
+			HoursBefore:      req.HoursBefore,
+			Channels:         req.Channels,
+		}
+		if err := preferences.SavePreferences(ctx, prefs); err != nil {
+			return fmt.Errorf("error saving preferences: %w", err)
+		}
+
+		api.Encode(w, http.StatusOK, prefs)
+		return nil
+	}
+}
@@ -0,0 +1,147 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Recipient struct {
+	UserId string
+	Email  string
+}
+
+type Message struct {
+	Subject string
+	Body    string
+}
+
+// Channel delivers notifications to users, such as by email
+type Channel interface {
+	// Name identifies the channel in user preferences
+	Name() string
+	Send(ctx context.Context, to *Recipient, msg *Message) error
+}
+
+const ChannelEmail = "email"
+
+// SMTPChannel sends notifications as plain text email through an SMTP server
+type SMTPChannel struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPChannel creates a channel sending from the given address.
+// Credentials are optional, servers that do not need them can be given an empty username.
+func NewSMTPChannel(host, port, username, password, from string) *SMTPChannel {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &SMTPChannel{
+		addr: net.JoinHostPort(host, port),
+		from: from,
+		auth: auth,
+	}
+}
+
+func (s *SMTPChannel) Name() string {
+	return ChannelEmail
+}
+
+func (s *SMTPChannel) Send(_ context.Context, to *Recipient, msg *Message) error {
+	if to.Email == "" {
+		return fmt.Errorf("no email address for user %s", to.UserId)
+	}
+	headers := []string{
+		"From: " + s.from,
+		"To: " + to.Email,
+		"Subject: " + msg.Subject,
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+	}
+	body := strings.Join(headers, "\r\n") + "\r\n\r\n" + strings.ReplaceAll(msg.Body, "\n", "\r\n")
+	return smtp.SendMail(s.addr, s.auth, s.from, []string{to.Email}, []byte(body))
+}
+
+const (
+	DefaultHoursBefore = 24
+	MaxHoursBefore     = 72
+)
+
+// Preferences are the notifications a user opted in to
+type Preferences struct {
+	UserId string `db:"user_id" json:"-"`
+	Email  string `db:"email" json:"email"`
+	// remind the user to make picks for events they have not picked
+	RemindersEnabled bool `db:"reminders_enabled" json:"reminders_enabled"`
+	// how long before an event starts the reminder is sent
+	HoursBefore int       `db:"hours_before" json:"hours_before"`
+	Channels    []string  `db:"channels" json:"channels"`
+	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
+}
+
+type PreferencesRepository interface {
+	// GetPreferences returns nil if the user never saved any preferences
+	GetPreferences(ctx context.Context, userId string) (*Preferences, error)
+	SavePreferences(ctx context.Context, prefs *Preferences) error
+	// GetReminderRecipients returns the preferences of every user with reminders enabled
+	GetReminderRecipients(ctx context.Context) ([]*Preferences, error)
+	// ClaimReminder records that the user is being reminded of the event over the channel,
+	// returning false if they already were. Claims are shared by all instances.
+	ClaimReminder(ctx context.Context, userId, eventId, channel string) (bool, error)
+	// ReleaseReminder removes a claim so a failed reminder is retried
+	ReleaseReminder(ctx context.Context, userId, eventId, channel string) error
+}
+
+type PostgresPreferences struct {
+	client *pgxpool.Pool
+}
+
+func NewPostgresPreferences(client *pgxpool.Pool) *PostgresPreferences {
+	return &PostgresPreferences{
+		client: client,
+	}
+}
+
+func (p *PostgresPreferences) GetPreferences(ctx context.Context, userId string) (*Preferences, error) {
+	rows, _ := p.client.Query(ctx, "SELECT * FROM notification_preferences WHERE user_id = $1", userId)
+	prefs, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[Preferences])
+	if err != nil || len(prefs) == 0 {
+		return nil, err
+	}
+	return prefs[0], nil
+}
+
+func (p *PostgresPreferences) SavePreferences(ctx context.Context, prefs *Preferences) error {
+	if _, err := p.client.Exec(ctx, "INSERT INTO notification_preferences (user_id, email, reminders_enabled, hours_before, channels) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (user_id) DO UPDATE SET email = EXCLUDED.email, reminders_enabled = EXCLUDED.reminders_enabled, hours_before = EXCLUDED.hours_before, channels = EXCLUDED.channels, updated_at = CURRENT_TIMESTAMP", prefs.UserId, prefs.Email, prefs.RemindersEnabled, prefs.HoursBefore, prefs.Channels); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (p *PostgresPreferences) GetReminderRecipients(ctx context.Context) ([]*Preferences, error) {
+	rows, _ := p.client.Query(ctx, "SELECT * FROM notification_preferences WHERE reminders_enabled")
+	return pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[Preferences])
+}
+
+func (p *PostgresPreferences) ClaimReminder(ctx context.Context, userId, eventId, channel string) (bool, error) {
+	tag, err := p.client.Exec(ctx, "INSERT INTO sent_reminders (user_id, event_id, channel) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING", userId, eventId, channel)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (p *PostgresPreferences) ReleaseReminder(ctx context.Context, userId, eventId, channel string) error {
+	_, err := p.client.Exec(ctx, "DELETE FROM sent_reminders WHERE user_id = $1 AND event_id = $2 AND channel = $3", userId, eventId, channel)
+	return err
+}
@@ -0,0 +1,102 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testMail struct {
+	from string
+	to   []string
+	data string
+}
+
+// Starts a minimal SMTP server on a local port that records the mail it receives
+func startTestSMTPServer(t *testing.T) (string, <-chan testMail) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	mails := make(chan testMail, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+		reply("220 localhost ESMTP")
+
+		var mail testMail
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.TrimRight(line, "\r\n")
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "MAIL FROM:"):
+				mail.from = strings.Trim(strings.TrimPrefix(cmd, "MAIL FROM:"), "<>")
+				reply("250 OK")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				mail.to = append(mail.to, strings.Trim(strings.TrimPrefix(cmd, "RCPT TO:"), "<>"))
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 end data with <CR><LF>.<CR><LF>")
+				var data strings.Builder
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				mail.data = data.String()
+				mails <- mail
+				reply("250 OK")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+
+	return listener.Addr().String(), mails
+}
+
+func TestSMTPChannel(t *testing.T) {
+	addr, mails := startTestSMTPServer(t)
+	host, port, _ := net.SplitHostPort(addr)
+
+	channel := NewSMTPChannel(host, port, "", "", "picks@example.com")
+	err := channel.Send(context.Background(), &Recipient{UserId: "1", Email: "user@example.com"}, &Message{
+		Subject: "Make your picks for UFC 300",
+		Body:    "UFC 300 starts soon.\nPicks close when the event starts.\n",
+	})
+	require.NoError(t, err)
+
+	mail := <-mails
+	assert.Equal(t, "picks@example.com", mail.from)
+	assert.Equal(t, []string{"user@example.com"}, mail.to)
+	assert.Contains(t, mail.data, "To: user@example.com\r\n")
+	assert.Contains(t, mail.data, "Subject: Make your picks for UFC 300\r\n")
+	assert.Contains(t, mail.data, "\r\n\r\nUFC 300 starts soon.\r\nPicks close when the event starts.\r\n")
+
+	t.Run("should not send without an email address", func(t *testing.T) {
+		err := channel.Send(context.Background(), &Recipient{UserId: "1"}, &Message{})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,132 @@
+package odds
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Provider fetches the current moneyline odds for upcoming fights
+type Provider interface {
+	// Returns American moneyline odds keyed by fighter name
+	FetchMoneylines(ctx context.Context, league string) (map[string]int, error)
+}
+
+// OddsAPIProvider reads odds from The Odds API (https://the-odds-api.com)
+type OddsAPIProvider struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+func NewOddsAPIProvider(apiKey string) *OddsAPIProvider {
+	return &OddsAPIProvider{
+		baseURL: "https://api.the-odds-api.com",
+		apiKey:  apiKey,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type oddsAPIEvent struct {
+	Bookmakers []struct {
+		Markets []struct {
+			Key      string `json:"key"`
+			Outcomes []struct {
+				Name  string  `json:"name"`
+				Price float64 `json:"price"`
+			} `json:"outcomes"`
+		} `json:"markets"`
+	} `json:"bookmakers"`
+}
+
+// The Odds API groups every promotion under a single MMA sport,
+// so the league is not used to narrow the request.
+func (p *OddsAPIProvider) FetchMoneylines(ctx context.Context, _ string) (map[string]int, error) {
+	query := url.Values{}
+	query.Set("apiKey", p.apiKey)
+	query.Set("regions", "us")
+	query.Set("markets", "h2h")
+	query.Set("oddsFormat", "american")
+	u := fmt.Sprintf("%s/v4/sports/mma_mixed_martial_arts/odds?%s", p.baseURL, query.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch odds: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status fetching odds: %d", resp.StatusCode)
+	}
+
+	var events []oddsAPIEvent
+	if err := json.NewDecoder(resp.Body).Decode(&events); err != nil {
+		return nil, err
+	}
+
+	lines := make(map[string]int)
+	for _, event := range events {
+		// use the first bookmaker offering a moneyline
+	bookmakers:
+		for _, bookmaker := range event.Bookmakers {
+			for _, market := range bookmaker.Markets {
+				if market.Key != "h2h" {
+					continue
+				}
+				for _, outcome := range market.Outcomes {
+					lines[outcome.Name] = int(outcome.Price)
+				}
+				break bookmakers
+			}
+		}
+	}
+
+	return lines, nil
+}
+
+// SnapshotRepository stores the odds of an event as they were when picks locked
+type SnapshotRepository interface {
+	GetOdds(ctx context.Context, eventId string) (map[string]int, error)
+	SaveOdds(ctx context.Context, eventId string, odds map[string]int) error
+}
+
+type PostgresSnapshots struct {
+	client *pgxpool.Pool
+}
+
+func NewPostgresSnapshots(client *pgxpool.Pool) *PostgresSnapshots {
+	return &PostgresSnapshots{
+		client: client,
+	}
+}
+
+func (p *PostgresSnapshots) GetOdds(ctx context.Context, eventId string) (map[string]int, error) {
+	rows, _ := p.client.Query(ctx, "SELECT fighter, price FROM odds_snapshots WHERE event_id = $1", eventId)
+	odds := make(map[string]int)
+	var fighter string
+	var price int
+	if _, err := pgx.ForEachRow(rows, []any{&fighter, &price}, func() error {
+		odds[fighter] = price
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return odds, nil
+}
+
+func (p *PostgresSnapshots) SaveOdds(ctx context.Context, eventId string, odds map[string]int) error {
+	var batch pgx.Batch
+	for fighter, price := range odds {
+		batch.Queue("INSERT INTO odds_snapshots (event_id, fighter, price) VALUES ($1, $2, $3) ON CONFLICT (event_id, fighter) DO UPDATE SET price = EXCLUDED.price, captured_at = CURRENT_TIMESTAMP", eventId, fighter, price)
+	}
+	return p.client.SendBatch(ctx, &batch).Close()
+}
@@ -0,0 +1,30 @@
+package odds
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOddsAPIProvider(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v4/sports/mma_mixed_martial_arts/odds", r.URL.Path)
+		assert.Equal(t, "test-key", r.URL.Query().Get("apiKey"))
+		assert.Equal(t, "american", r.URL.Query().Get("oddsFormat"))
+		http.ServeFile(w, r, "testdata/odds.json")
+	}))
+	defer ts.Close()
+
+	provider := &OddsAPIProvider{baseURL: ts.URL, apiKey: "test-key", client: ts.Client()}
+	lines, err := provider.FetchMoneylines(context.Background(), "ufc")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{
+		"Alex Pereira": -135,
+		"Jamahal Hill": 114,
+		"Zhang Weili":  -550,
+		"Yan Xiaonan":  400,
+	}, lines)
+}
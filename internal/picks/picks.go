@@ -14,6 +14,7 @@ import (
 type Picks struct {
 	UserId    string    `db:"user_id" json:"user_id"`
 	EventId   string    `db:"event_id" json:"event_id"`
+	League    string    `db:"league" json:"league"`
 	Winners   []string  `db:"picks" json:"winners"`
 	Score     *int      `db:"score" json:"score,omitempty"`
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
@@ -28,7 +29,7 @@ type EventPicksRepository interface {
 	GetUserPicksByEvent(ctx context.Context, user *auth.User, eventId string) (*Picks, error)
 	GetAllUserPicks(ctx context.Context, user *auth.User) ([]*Picks, error)
 	GetPicksByFilter(ctx context.Context, filter *PicksFilter) ([]*Picks, error)
-	SavePicks(ctx context.Context, user *auth.User, eventId string, picks []string) error
+	SavePicks(ctx context.Context, user *auth.User, league, eventId string, picks []string) error
 	BatchScorePicks(ctx context.Context, picks []*Picks) []error
 }
 
@@ -92,8 +93,8 @@ func (p *PostgresEventPicks) GetPicksByFilter(ctx context.Context, filter *Picks
 	return picks, nil
 }
 
-func (p *PostgresEventPicks) SavePicks(ctx context.Context, user *auth.User, eventId string, picks []string) error {
-	if _, err := p.client.Exec(ctx, "INSERT INTO picks VALUES ($1, $2, $3) ON CONFLICT (user_id, event_id) DO UPDATE SET picks = EXCLUDED.picks, created_at = CURRENT_TIMESTAMP", user.Id, eventId, picks); err != nil {
+func (p *PostgresEventPicks) SavePicks(ctx context.Context, user *auth.User, league, eventId string, picks []string) error {
+	if _, err := p.client.Exec(ctx, "INSERT INTO picks (user_id, event_id, league, picks) VALUES ($1, $2, $3, $4) ON CONFLICT (user_id, event_id) DO UPDATE SET picks = EXCLUDED.picks, created_at = CURRENT_TIMESTAMP", user.Id, eventId, league, picks); err != nil {
 		return err
 	}
 	return nil
@@ -0,0 +1,52 @@
+package resolutions
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Resolution is an admin decision on the winner of a fight, used when data sources disagree
+type Resolution struct {
+	EventId   string    `db:"event_id" json:"event_id"`
+	Fighters  []string  `db:"fighters" json:"fighters"`
+	Winner    string    `db:"winner" json:"winner"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+type FightResolutionRepository interface {
+	GetResolutions(ctx context.Context, eventId string) ([]*Resolution, error)
+	SaveResolution(ctx context.Context, resolution *Resolution) error
+}
+
+type PostgresFightResolutions struct {
+	client *pgxpool.Pool
+}
+
+func NewPostgresFightResolutions(client *pgxpool.Pool) *PostgresFightResolutions {
+	return &PostgresFightResolutions{
+		client: client,
+	}
+}
+
+func (p *PostgresFightResolutions) GetResolutions(ctx context.Context, eventId string) ([]*Resolution, error) {
+	rows, _ := p.client.Query(ctx, "SELECT * FROM fight_resolutions WHERE event_id = $1", eventId)
+	resolutions, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[Resolution])
+	if err != nil {
+		return nil, err
+	}
+	return resolutions, nil
+}
+
+func (p *PostgresFightResolutions) SaveResolution(ctx context.Context, resolution *Resolution) error {
+	// fighters are stored sorted so the same fight always has the same key
+	fighters := slices.Clone(resolution.Fighters)
+	slices.Sort(fighters)
+	if _, err := p.client.Exec(ctx, "INSERT INTO fight_resolutions (event_id, fighters, winner) VALUES ($1, $2, $3) ON CONFLICT (event_id, fighters) DO UPDATE SET winner = EXCLUDED.winner, created_at = CURRENT_TIMESTAMP", resolution.EventId, fighters, resolution.Winner); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/thebenkogan/ufc/internal/util/api"
+	"github.com/thebenkogan/ufc/internal/util/logs"
+)
+
+// Reports whether the method changes state, safe methods are never checked
+func isUnsafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return false
+	}
+	return true
+}
+
+// csrfProtect rejects state-changing requests made by browsers from origins other than
+// the allowed ones and the server's own. Requests without an origin, such as from the
+// cronjob and scripts with personal access tokens, are not made by browsers and pass.
+func csrfProtect(allowed []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isUnsafeMethod(r.Method) || api.AllowedOrigin(r, allowed) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := logs.WithRequestLogger(r)
+		logs.Logger(ctx).Warn("rejected cross-origin request", "origin", api.RequestOrigin(r))
+		http.Error(w, "cross-origin request forbidden", http.StatusForbidden)
+	})
+}
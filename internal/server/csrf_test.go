@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thebenkogan/ufc/internal/auth"
+	"github.com/thebenkogan/ufc/internal/util/api"
+)
+
+func TestCSRFProtect(t *testing.T) {
+	reached := false
+	h := csrfProtect([]string{"http://localhost:5173"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+
+	csrfTests := []struct {
+		name    string
+		method  string
+		origin  string
+		referer string
+		allowed bool
+	}{
+		{"allowed origin", http.MethodPost, "http://localhost:5173", "", true},
+		{"same origin", http.MethodPost, "http://api.example.com", "", true},
+		{"no origin", http.MethodPost, "", "", true},
+		{"cross origin", http.MethodPost, "https://evil.example.com", "", false},
+		{"cross origin delete", http.MethodDelete, "https://evil.example.com", "", false},
+		{"cross origin put", http.MethodPut, "https://evil.example.com", "", false},
+		{"opaque origin", http.MethodPost, "null", "", false},
+		{"allowed origin with other scheme", http.MethodPost, "https://localhost:5173", "", false},
+		{"cross origin referer", http.MethodPost, "", "https://evil.example.com/page", false},
+		{"allowed referer", http.MethodPost, "", "http://localhost:5173/schedule", true},
+		{"cross origin get", http.MethodGet, "https://evil.example.com", "", true},
+	}
+
+	for _, tt := range csrfTests {
+		t.Run(tt.name, func(t *testing.T) {
+			reached = false
+			r := httptest.NewRequest(tt.method, "http://api.example.com/events/1/picks", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.referer != "" {
+				r.Header.Set("Referer", tt.referer)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.allowed, reached)
+			if !tt.allowed {
+				assert.Equal(t, http.StatusForbidden, w.Code)
+			}
+		})
+	}
+}
+
+// recordingAuth counts the requests that reach authentication, as if they carried a session cookie
+type recordingAuth struct {
+	calls int
+}
+
+func (a *recordingAuth) record() api.Handler {
+	return func(_ context.Context, w http.ResponseWriter, _ *http.Request) error {
+		a.calls++
+		http.Error(w, "reached authentication", http.StatusTeapot)
+		return nil
+	}
+}
+
+func (a *recordingAuth) HandleBeginAuth() api.Handler         { return a.record() }
+func (a *recordingAuth) HandleAuthCallback() api.Handler      { return a.record() }
+func (a *recordingAuth) HandleLogout() api.Handler            { return a.record() }
+func (a *recordingAuth) HandleLogoutAll() api.Handler         { return a.record() }
+func (a *recordingAuth) Middleware(_ api.Handler) api.Handler { return a.record() }
+
+func TestForgedRequestsRejected(t *testing.T) {
+	oauth := &recordingAuth{}
+	srv := NewServer(Deps{OAuth: oauth, Authz: auth.NewAuthorizer(nil, "")})
+
+	forged := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodPost, "/events/1/picks"},
+		{http.MethodPost, "/leagues/pfl/events/1/picks"},
+		{http.MethodPut, "/me/notifications"},
+		{http.MethodPut, "/me/profile"},
+		{http.MethodPost, "/me/tokens"},
+		{http.MethodPost, "/me/feeds/rotate"},
+		{http.MethodPost, "/logout"},
+		{http.MethodPost, "/logout/all"},
+		{http.MethodPost, "/admin/webhooks"},
+	}
+	for _, f := range forged {
+		t.Run(f.method+" "+f.path, func(t *testing.T) {
+			r := httptest.NewRequest(f.method, f.path, nil)
+			r.Header.Set("Origin", "https://evil.example.com")
+			r.AddCookie(&http.Cookie{Name: "session_id", Value: "stolen"})
+			w := httptest.NewRecorder()
+			srv.ServeHTTP(w, r)
+
+			assert.Equal(t, http.StatusForbidden, w.Code)
+			assert.Zero(t, oauth.calls, "forged request should not reach authentication")
+		})
+	}
+
+	// the client's own requests go through
+	r := httptest.NewRequest(http.MethodPost, "/events/1/picks", nil)
+	r.Header.Set("Origin", "http://localhost:5173")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusTeapot, w.Code)
+	assert.Equal(t, 1, oauth.calls)
+}
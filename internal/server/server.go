@@ -7,18 +7,44 @@ import (
 	"github.com/thebenkogan/ufc/internal/auth"
 	"github.com/thebenkogan/ufc/internal/cache"
 	"github.com/thebenkogan/ufc/internal/events"
+	"github.com/thebenkogan/ufc/internal/feeds"
+	"github.com/thebenkogan/ufc/internal/live"
+	"github.com/thebenkogan/ufc/internal/notify"
 	"github.com/thebenkogan/ufc/internal/picks"
+	"github.com/thebenkogan/ufc/internal/resolutions"
+	"github.com/thebenkogan/ufc/internal/users"
 	"github.com/thebenkogan/ufc/internal/util/api"
 	"github.com/thebenkogan/ufc/internal/util/logs"
+	"github.com/thebenkogan/ufc/internal/webhooks"
 )
 
-func NewServer(oauth auth.OIDCAuth, eventScraper events.EventScraper, eventCache cache.EventCacheRepository, eventPicks picks.EventPicksRepository) http.Handler {
+// origins of the client, which may make credentialed requests
+var allowedOrigins = []string{"http://localhost:5173"}
+
+// Deps are the dependencies of the server's handlers
+type Deps struct {
+	OAuth            auth.OIDCAuth
+	Authz            *auth.Authorizer
+	AccessTokens     auth.AccessTokenRepository
+	EventScraper     events.EventScraper
+	EventCache       cache.EventCacheRepository
+	EventPicks       picks.EventPicksRepository
+	FightResolutions resolutions.FightResolutionRepository
+	Broker           live.Broker
+	Webhooks         webhooks.WebhookRepository
+	Dispatcher       webhooks.Dispatcher
+	Preferences      notify.PreferencesRepository
+	FeedTokens       feeds.FeedTokenRepository
+	Users            users.UserRepository
+}
+
+func NewServer(d Deps) http.Handler {
 	mux := http.NewServeMux()
-	addRoutes(mux, oauth, eventScraper, eventCache, eventPicks)
+	addRoutes(mux, d)
 	handler := cors.New(cors.Options{
-		AllowedOrigins:   []string{"http://localhost:5173"},
+		AllowedOrigins:   allowedOrigins,
 		AllowCredentials: true,
-	}).Handler(mux)
+	}).Handler(csrfProtect(allowedOrigins, mux))
 	return handler
 }
 
@@ -33,25 +59,70 @@ func handler(h api.Handler) http.HandlerFunc {
 	}
 }
 
-func addRoutes(
-	mux *http.ServeMux,
-	oauth auth.OIDCAuth,
-	eventScraper events.EventScraper,
-	eventCache cache.EventCacheRepository,
-	eventPicks picks.EventPicksRepository,
-) {
-	mux.Handle("/login", handler(oauth.HandleBeginAuth()))
-	mux.Handle("/auth/google/callback", handler(oauth.HandleAuthCallback()))
-	mux.Handle("/me", handler(auth.HandleMe(oauth)))
-
-	mux.Handle("GET /schedule", handler((events.HandleGetSchedule(eventScraper, eventCache))))
-
-	mux.Handle("POST /events/score_job", handler((events.HandleScoreJob(eventScraper, eventCache, eventPicks))))
-	mux.Handle("GET /events/picks", handler(oauth.Middleware(events.HandleGetAllPicks(eventScraper, eventCache, eventPicks))))
-	mux.Handle("GET /events/{id}", handler((events.HandleGetEvent(eventScraper, eventCache))))
-
-	mux.Handle("GET /events/{id}/picks", handler(oauth.Middleware(events.HandleGetPicks(eventScraper, eventCache, eventPicks))))
-	mux.Handle("POST /events/{id}/picks", handler(oauth.Middleware(events.HandlePostPicks(eventScraper, eventCache, eventPicks))))
+func addRoutes(mux *http.ServeMux, d Deps) {
+	authenticate := d.Authz.Authenticate(d.OAuth)
+	// requires a user or the service with the role
+	requireRole := func(role auth.Role, h api.Handler) http.HandlerFunc {
+		return handler(authenticate(d.Authz.Require(role, h)))
+	}
+
+	mux.Handle("/login", handler(d.OAuth.HandleBeginAuth()))
+	mux.Handle("/login/{provider}", handler(d.OAuth.HandleBeginAuth()))
+	mux.Handle("/auth/{provider}/callback", handler(d.OAuth.HandleAuthCallback()))
+	mux.Handle("POST /logout", handler(d.OAuth.HandleLogout()))
+	mux.Handle("POST /logout/all", handler(d.OAuth.HandleLogoutAll()))
+	mux.Handle("/me", handler(auth.HandleMe(d.OAuth)))
+	mux.Handle("GET /me/profile", handler(d.OAuth.Middleware(users.HandleGetProfile(d.Users))))
+	mux.Handle("PUT /me/profile", handler(d.OAuth.Middleware(users.HandlePutProfile(d.Users))))
+	mux.Handle("GET /users/{id}", handler((users.HandleGetUser(d.Users))))
+	mux.Handle("GET /me/tokens", handler(d.OAuth.Middleware(auth.HandleListAccessTokens(d.AccessTokens))))
+	mux.Handle("POST /me/tokens", handler(d.OAuth.Middleware(auth.HandlePostAccessToken(d.AccessTokens))))
+	mux.Handle("DELETE /me/tokens/{id}", handler(d.OAuth.Middleware(auth.HandleDeleteAccessToken(d.AccessTokens))))
+	mux.Handle("GET /me/notifications", handler(d.OAuth.Middleware(notify.HandleGetPreferences(d.Preferences))))
+	mux.Handle("PUT /me/notifications", handler(d.OAuth.Middleware(notify.HandlePutPreferences(d.Preferences))))
+	mux.Handle("GET /me/feeds", handler(d.OAuth.Middleware(feeds.HandleGetFeeds(d.FeedTokens))))
+	mux.Handle("POST /me/feeds/rotate", handler(d.OAuth.Middleware(feeds.HandleRotateFeeds(d.FeedTokens))))
+
+	mux.Handle("GET /schedule", handler((events.HandleGetSchedule(d.EventScraper, d.EventCache))))
+	mux.Handle("GET /schedule.ics", handler((events.HandleGetScheduleCalendar(d.EventScraper, d.EventCache))))
+	mux.Handle("GET /results.atom", handler((events.HandleGetResultsFeed(d.EventCache))))
+	mux.Handle("GET /feeds/{token}/picks.atom", handler((events.HandleGetUserPicksFeed(d.EventScraper, d.EventCache, d.EventPicks, d.FeedTokens))))
+	mux.Handle("GET /feeds/{token}/schedule.ics", handler((events.HandleGetUserCalendar(d.EventScraper, d.EventCache, d.EventPicks, d.FeedTokens, d.Preferences))))
+
+	mux.Handle("POST /events/score_job", requireRole(auth.RoleAdmin, events.HandleScoreJob(d.EventScraper, d.EventCache, d.EventPicks, d.Dispatcher)))
+	mux.Handle("GET /events/picks", handler(auth.WithScope(auth.ScopePicksRead, d.OAuth.Middleware(events.HandleGetAllPicks(d.EventScraper, d.EventCache, d.EventPicks)))))
+	mux.Handle("GET /events/{id}", handler((events.HandleGetEvent(d.EventScraper, d.EventCache))))
+
+	mux.Handle("GET /events/{id}/stream", handler((events.HandleStreamEvent(d.EventScraper, d.EventCache, d.Broker))))
+	mux.Handle("GET /events/{id}/leaderboard", handler(auth.WithScope(auth.ScopePicksRead, d.OAuth.Middleware(events.HandleLeaderboard(d.EventScraper, d.EventCache, d.EventPicks, d.Users, d.Broker, allowedOrigins)))))
+	mux.Handle("POST /events/{id}/resolutions", requireRole(auth.RoleCommissioner, events.HandlePostResolution(d.EventScraper, d.EventCache, d.FightResolutions)))
+
+	mux.Handle("GET /events/{id}/picks", handler(auth.WithScope(auth.ScopePicksRead, d.OAuth.Middleware(events.HandleGetPicks(d.EventScraper, d.EventCache, d.EventPicks)))))
+	mux.Handle("POST /events/{id}/picks", handler(auth.WithScope(auth.ScopePicksWrite, d.OAuth.Middleware(events.HandlePostPicks(d.EventScraper, d.EventCache, d.EventPicks)))))
+
+	mux.Handle("GET /leagues/{league}/schedule", handler((events.HandleGetSchedule(d.EventScraper, d.EventCache))))
+	mux.Handle("GET /leagues/{league}/schedule.ics", handler((events.HandleGetScheduleCalendar(d.EventScraper, d.EventCache))))
+	mux.Handle("GET /leagues/{league}/results.atom", handler((events.HandleGetResultsFeed(d.EventCache))))
+	mux.Handle("GET /leagues/{league}/events/{id}", handler((events.HandleGetEvent(d.EventScraper, d.EventCache))))
+	mux.Handle("GET /leagues/{league}/events/{id}/stream", handler((events.HandleStreamEvent(d.EventScraper, d.EventCache, d.Broker))))
+	mux.Handle("GET /leagues/{league}/events/{id}/leaderboard", handler(auth.WithScope(auth.ScopePicksRead, d.OAuth.Middleware(events.HandleLeaderboard(d.EventScraper, d.EventCache, d.EventPicks, d.Users, d.Broker, allowedOrigins)))))
+	mux.Handle("GET /leagues/{league}/events/{id}/picks", handler(auth.WithScope(auth.ScopePicksRead, d.OAuth.Middleware(events.HandleGetPicks(d.EventScraper, d.EventCache, d.EventPicks)))))
+	mux.Handle("POST /leagues/{league}/events/{id}/picks", handler(auth.WithScope(auth.ScopePicksWrite, d.OAuth.Middleware(events.HandlePostPicks(d.EventScraper, d.EventCache, d.EventPicks)))))
+	mux.Handle("POST /leagues/{league}/events/{id}/resolutions", requireRole(auth.RoleCommissioner, events.HandlePostResolution(d.EventScraper, d.EventCache, d.FightResolutions)))
+
+	mux.Handle("GET /admin/cache/events", requireRole(auth.RoleAdmin, events.HandleListCachedEvents(d.EventCache)))
+	mux.Handle("GET /admin/cache/events/{id}", requireRole(auth.RoleAdmin, events.HandleGetCachedEvent(d.EventCache)))
+	mux.Handle("DELETE /admin/cache/events/{id}", requireRole(auth.RoleAdmin, events.HandleDeleteCachedEvent(d.EventCache)))
+	mux.Handle("DELETE /admin/cache/leagues/{league}/latest", requireRole(auth.RoleAdmin, events.HandleDeleteCachedLatest(d.EventCache)))
+	mux.Handle("DELETE /admin/cache/leagues/{league}/schedule", requireRole(auth.RoleAdmin, events.HandleDeleteCachedSchedule(d.EventCache)))
+
+	mux.Handle("GET /admin/users/{id}/roles", requireRole(auth.RoleAdmin, d.Authz.HandleGetGrants()))
+	mux.Handle("PUT /admin/users/{id}/roles", requireRole(auth.RoleAdmin, d.Authz.HandlePutGrants()))
+
+	mux.Handle("POST /admin/webhooks", requireRole(auth.RoleAdmin, webhooks.HandlePostWebhook(d.Webhooks)))
+	mux.Handle("GET /admin/webhooks", requireRole(auth.RoleAdmin, webhooks.HandleListWebhooks(d.Webhooks)))
+	mux.Handle("DELETE /admin/webhooks/{id}", requireRole(auth.RoleAdmin, webhooks.HandleDeleteWebhook(d.Webhooks)))
+	mux.Handle("GET /admin/webhooks/{id}/deliveries", requireRole(auth.RoleAdmin, webhooks.HandleListDeliveries(d.Webhooks)))
 
 	mux.Handle("/", http.NotFoundHandler())
 }
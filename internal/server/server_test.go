@@ -42,6 +42,18 @@ func (a *testOAuth) HandleAuthCallback() api.Handler {
 	}
 }
 
+func (a *testOAuth) HandleLogout() api.Handler {
+	return func(_ context.Context, w http.ResponseWriter, r *http.Request) error {
+		panic("not implemented")
+	}
+}
+
+func (a *testOAuth) HandleLogoutAll() api.Handler {
+	return func(_ context.Context, w http.ResponseWriter, r *http.Request) error {
+		panic("not implemented")
+	}
+}
+
 func (a *testOAuth) Middleware(h api.Handler) api.Handler {
 	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 		userId := "user"
@@ -56,15 +68,21 @@ func (a *testOAuth) Middleware(h api.Handler) api.Handler {
 	}
 }
 
+type testDispatcher struct{}
+
+func (d *testDispatcher) Dispatch(_ context.Context, _, _ string, _ any) error {
+	return nil
+}
+
 type testEventScraper struct {
 	maker func(id string) *model.Event
 }
 
-func (s testEventScraper) ScrapeEvent(id string) (*model.Event, error) {
+func (s testEventScraper) ScrapeEvent(_ context.Context, _, id string) (*model.Event, error) {
 	return s.maker(id), nil
 }
 
-func (s testEventScraper) ScrapeSchedule() ([]*model.EventInfo, error) {
+func (s testEventScraper) ScrapeSchedule(_ context.Context, _ string) ([]*model.EventInfo, error) {
 	panic("unimplemented")
 }
 
@@ -86,7 +104,7 @@ func TestServer(t *testing.T) {
 	}
 	clearEventCache()
 
-	eventCache := cache.NewRedisEventCache(rdb)
+	eventCache := cache.NewRedisEventCache(rdb, cache.JSONCodec{})
 
 	pgUrl := fmt.Sprintf(
 		"postgres://%s:%s@%s:%s/%s",
@@ -131,7 +149,7 @@ func TestServer(t *testing.T) {
 			},
 		}
 
-		srv := server.NewServer(&testOAuth{}, testScraper, eventCache, nil)
+		srv := server.NewServer(server.Deps{OAuth: &testOAuth{}, Authz: auth.NewAuthorizer(nil, os.Getenv("CRONJOB_API_KEY")), EventScraper: testScraper, EventCache: eventCache, Dispatcher: &testDispatcher{}})
 		ts := httptest.NewServer(srv)
 		defer ts.Close()
 
@@ -203,7 +221,7 @@ func TestServer(t *testing.T) {
 			},
 		}
 
-		srv := server.NewServer(&testOAuth{}, testScraper, eventCache, eventPicks)
+		srv := server.NewServer(server.Deps{OAuth: &testOAuth{}, Authz: auth.NewAuthorizer(nil, os.Getenv("CRONJOB_API_KEY")), EventScraper: testScraper, EventCache: eventCache, EventPicks: eventPicks, Dispatcher: &testDispatcher{}})
 		ts := httptest.NewServer(srv)
 		defer ts.Close()
 
@@ -273,7 +291,7 @@ func TestServer(t *testing.T) {
 			},
 		}
 
-		srv := server.NewServer(&testOAuth{}, testScraper, eventCache, eventPicks)
+		srv := server.NewServer(server.Deps{OAuth: &testOAuth{}, Authz: auth.NewAuthorizer(nil, os.Getenv("CRONJOB_API_KEY")), EventScraper: testScraper, EventCache: eventCache, EventPicks: eventPicks, Dispatcher: &testDispatcher{}})
 		ts := httptest.NewServer(srv)
 		defer ts.Close()
 
@@ -326,7 +344,7 @@ func TestServer(t *testing.T) {
 		user1Id := "user1"
 		user2Id := "user2"
 		ids := []string{user1Id, user2Id, user1Id, user2Id}
-		srv := server.NewServer(&testOAuth{ids: ids}, testScraper, eventCache, eventPicks)
+		srv := server.NewServer(server.Deps{OAuth: &testOAuth{ids: ids}, Authz: auth.NewAuthorizer(nil, os.Getenv("CRONJOB_API_KEY")), EventScraper: testScraper, EventCache: eventCache, EventPicks: eventPicks, Dispatcher: &testDispatcher{}})
 		ts := httptest.NewServer(srv)
 		defer ts.Close()
 
@@ -0,0 +1,106 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/thebenkogan/ufc/internal/auth"
+	"github.com/thebenkogan/ufc/internal/util/api"
+)
+
+const maxDisplayNameLength = 32
+
+// HandleGetUser serves the public profile of any user
+func HandleGetUser(users UserRepository) api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		user, err := users.GetUser(ctx, r.PathValue("id"))
+		if err != nil {
+			return fmt.Errorf("error getting user: %w", err)
+		}
+		if user == nil {
+			http.Error(w, "user not found", http.StatusNotFound)
+			return nil
+		}
+
+		api.Encode(w, http.StatusOK, user.Profile())
+		return nil
+	}
+}
+
+// Returns the logged in user's stored details
+func currentUser(ctx context.Context, users UserRepository) (*User, error) {
+	user := auth.GetUser(ctx)
+	if user == nil {
+		return nil, fmt.Errorf("no user in context")
+	}
+	stored, err := users.GetUser(ctx, user.Id)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user: %w", err)
+	}
+	if stored == nil {
+		return nil, fmt.Errorf("logged in user %s is not stored", user.Id)
+	}
+	return stored, nil
+}
+
+func HandleGetProfile(users UserRepository) api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		user, err := currentUser(ctx, users)
+		if err != nil {
+			return err
+		}
+
+		api.Encode(w, http.StatusOK, user)
+		return nil
+	}
+}
+
+type PutProfileRequest struct {
+	// empty to show the name from the identity provider
+	DisplayName string `json:"display_name"`
+}
+
+// Returns the trimmed display name, or an error explaining why it is not allowed
+func validDisplayName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) > maxDisplayNameLength {
+		return "", fmt.Errorf("display_name must be at most %d characters", maxDisplayNameLength)
+	}
+	if strings.ContainsFunc(name, unicode.IsControl) {
+		return "", fmt.Errorf("display_name must not contain control characters")
+	}
+	return name, nil
+}
+
+func HandlePutProfile(users UserRepository) api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		user, err := currentUser(ctx, users)
+		if err != nil {
+			return err
+		}
+
+		var req PutProfileRequest
+		api.Decode(r, &req)
+
+		name, err := validDisplayName(req.DisplayName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil
+		}
+		user.DisplayName = nil
+		if name != "" {
+			user.DisplayName = &name
+		}
+
+		if err := users.SetDisplayName(ctx, user.Id, user.DisplayName); err != nil {
+			return fmt.Errorf("error setting display name: %w", err)
+		}
+
+		api.Encode(w, http.StatusOK, user)
+		return nil
+	}
+}
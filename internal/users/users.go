@@ -0,0 +1,93 @@
+package users
+
+import (
+	"context"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/thebenkogan/ufc/internal/auth"
+)
+
+// User is a user as stored when they last logged in
+type User struct {
+	Id    string `db:"id" json:"id"`
+	Email string `db:"email" json:"email"`
+	// name from the identity provider
+	Name string `db:"name" json:"name"`
+	// chosen by the user, shown instead of their name if set
+	DisplayName *string   `db:"display_name" json:"display_name"`
+	Avatar      string    `db:"avatar" json:"avatar"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at" json:"-"`
+}
+
+// Profile is what other users can see of a user
+type Profile struct {
+	Id        string    `json:"id"`
+	Name      string    `json:"name"`
+	Avatar    string    `json:"avatar"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (u *User) Profile() *Profile {
+	name := u.Name
+	if u.DisplayName != nil {
+		name = *u.DisplayName
+	}
+	return &Profile{
+		Id:        u.Id,
+		Name:      name,
+		Avatar:    u.Avatar,
+		CreatedAt: u.CreatedAt,
+	}
+}
+
+type UserRepository interface {
+	// UpsertUser saves the user's details from their identity provider, keeping their display name
+	UpsertUser(ctx context.Context, user *auth.User) error
+	// GetUser returns nil if the user never logged in
+	GetUser(ctx context.Context, id string) (*User, error)
+	// GetUsers returns the users among ids that have logged in
+	GetUsers(ctx context.Context, ids []string) ([]*User, error)
+	// SetDisplayName sets the user's display name, nil shows their name instead
+	SetDisplayName(ctx context.Context, id string, displayName *string) error
+}
+
+type PostgresUsers struct {
+	client *pgxpool.Pool
+}
+
+func NewPostgresUsers(client *pgxpool.Pool) *PostgresUsers {
+	return &PostgresUsers{
+		client: client,
+	}
+}
+
+func (p *PostgresUsers) UpsertUser(ctx context.Context, user *auth.User) error {
+	if _, err := p.client.Exec(ctx, "INSERT INTO users (id, email, name, avatar) VALUES ($1, $2, $3, $4) ON CONFLICT (id) DO UPDATE SET email = EXCLUDED.email, name = EXCLUDED.name, avatar = EXCLUDED.avatar, updated_at = CURRENT_TIMESTAMP", user.Id, user.Email, user.Name, user.Picture); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (p *PostgresUsers) GetUser(ctx context.Context, id string) (*User, error) {
+	rows, _ := p.client.Query(ctx, "SELECT * FROM users WHERE id = $1", id)
+	users, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[User])
+	if err != nil || len(users) == 0 {
+		return nil, err
+	}
+	return users[0], nil
+}
+
+func (p *PostgresUsers) GetUsers(ctx context.Context, ids []string) ([]*User, error) {
+	rows, _ := p.client.Query(ctx, "SELECT * FROM users WHERE id = ANY($1)", ids)
+	return pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[User])
+}
+
+func (p *PostgresUsers) SetDisplayName(ctx context.Context, id string, displayName *string) error {
+	if _, err := p.client.Exec(ctx, "UPDATE users SET display_name = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1", id, displayName); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thebenkogan/ufc/internal/auth"
+)
+
+type testUsers struct {
+	users map[string]*User
+}
+
+func (u *testUsers) UpsertUser(_ context.Context, user *auth.User) error {
+	stored, ok := u.users[user.Id]
+	if !ok {
+		stored = &User{Id: user.Id, CreatedAt: time.Now()}
+		u.users[user.Id] = stored
+	}
+	stored.Email, stored.Name, stored.Avatar = user.Email, user.Name, user.Picture
+	return nil
+}
+
+func (u *testUsers) GetUser(_ context.Context, id string) (*User, error) {
+	if user, ok := u.users[id]; ok {
+		copied := *user
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (u *testUsers) GetUsers(ctx context.Context, ids []string) ([]*User, error) {
+	found := make([]*User, 0, len(ids))
+	for _, id := range ids {
+		if user, _ := u.GetUser(ctx, id); user != nil {
+			found = append(found, user)
+		}
+	}
+	return found, nil
+}
+
+func (u *testUsers) SetDisplayName(_ context.Context, id string, displayName *string) error {
+	u.users[id].DisplayName = displayName
+	return nil
+}
+
+func TestValidDisplayName(t *testing.T) {
+	nameTests := []struct {
+		name     string
+		expected string
+		valid    bool
+	}{
+		{"  Bones  ", "Bones", true},
+		{"", "", true},
+		{"Conor McGregör", "Conor McGregör", true},
+		{strings.Repeat("a", maxDisplayNameLength), strings.Repeat("a", maxDisplayNameLength), true},
+		{strings.Repeat("a", maxDisplayNameLength+1), "", false},
+		{"line\nbreak", "", false},
+	}
+
+	for _, tt := range nameTests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, err := validDisplayName(tt.name)
+			if !tt.valid {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, name)
+		})
+	}
+}
+
+func TestProfileHandlers(t *testing.T) {
+	repo := &testUsers{users: make(map[string]*User)}
+	user := &auth.User{Id: "1", Email: "user@gmail.com", Name: "Jon Jones", Picture: "https://example.com/jon.png"}
+	require.NoError(t, repo.UpsertUser(context.Background(), user))
+
+	getProfile := func(id string) (int, *Profile) {
+		r := httptest.NewRequest(http.MethodGet, "/users/"+id, nil)
+		r.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		require.NoError(t, HandleGetUser(repo)(r.Context(), w, r))
+		if w.Code != http.StatusOK {
+			return w.Code, nil
+		}
+		var profile Profile
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&profile))
+		return w.Code, &profile
+	}
+	putDisplayName := func(name string) *httptest.ResponseRecorder {
+		body, err := json.Marshal(PutProfileRequest{DisplayName: name})
+		require.NoError(t, err)
+		r := httptest.NewRequest(http.MethodPut, "/me/profile", strings.NewReader(string(body)))
+		ctx := auth.WithUser(r.Context(), user)
+		w := httptest.NewRecorder()
+		require.NoError(t, HandlePutProfile(repo)(ctx, w, r.WithContext(ctx)))
+		return w
+	}
+
+	code, _ := getProfile("unknown")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, profile := getProfile("1")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "Jon Jones", profile.Name)
+	assert.Equal(t, "https://example.com/jon.png", profile.Avatar)
+
+	w := putDisplayName(" Bones ")
+	require.Equal(t, http.StatusOK, w.Code)
+	_, profile = getProfile("1")
+	assert.Equal(t, "Bones", profile.Name)
+
+	// logging in again keeps the display name
+	require.NoError(t, repo.UpsertUser(context.Background(), user))
+	_, profile = getProfile("1")
+	assert.Equal(t, "Bones", profile.Name)
+
+	w = putDisplayName(strings.Repeat("a", maxDisplayNameLength+1))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// clearing the display name shows the name from the identity provider
+	w = putDisplayName("")
+	require.Equal(t, http.StatusOK, w.Code)
+	_, profile = getProfile("1")
+	assert.Equal(t, "Jon Jones", profile.Name)
+}
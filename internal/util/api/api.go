@@ -2,9 +2,16 @@ package api
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
 )
 
 type Handler func(ctx context.Context, w http.ResponseWriter, r *http.Request) error
@@ -21,3 +28,93 @@ func Encode[T any](w http.ResponseWriter, status int, v T) {
 func Decode[T any](r *http.Request, v *T) {
 	_ = json.NewDecoder(r.Body).Decode(v)
 }
+
+// Returns a strong ETag for the JSON encoding of v
+func ETag(v any) (string, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`, nil
+}
+
+// Sets the validators for the response and reports whether the request's
+// conditional headers match them, in which case a 304 has been written.
+// If-None-Match takes precedence over If-Modified-Since as in RFC 9110.
+// A zero lastModified is not sent.
+func NotModified(w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time) bool {
+	w.Header().Set("ETag", etag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if !etagMatches(inm, etag) {
+			return false
+		}
+	} else {
+		ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		if err != nil || lastModified.IsZero() || lastModified.Truncate(time.Second).After(ims) {
+			return false
+		}
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// weak comparison of an If-None-Match header against etag
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Sets Cache-Control so clients reuse the response for maxAge
+// and then revalidate it. A maxAge of 0 makes clients always revalidate.
+func CacheControl(w http.ResponseWriter, maxAge time.Duration) {
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d, must-revalidate", int(maxAge.Seconds())))
+}
+
+// Writes a server-sent event with the JSON encoding of v as its data
+func WriteEvent(w http.ResponseWriter, event string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
+
+// Returns the origin a browser made the request from, or "" if it was not made by a browser.
+// Browsers send Origin on WebSocket handshakes and every cross-origin request that changes state,
+// older ones only Referer.
+func RequestOrigin(r *http.Request) string {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		return origin
+	}
+	if referer := r.Header.Get("Referer"); referer != "" {
+		u, err := url.Parse(referer)
+		if err != nil || u.Host == "" {
+			return "null"
+		}
+		return u.Scheme + "://" + u.Host
+	}
+	return ""
+}
+
+// Reports whether the request was made from one of the allowed origins or the server's own,
+// or was not made by a browser, such as by the cronjob and scripts with personal access tokens
+func AllowedOrigin(r *http.Request, allowed []string) bool {
+	origin := RequestOrigin(r)
+	if origin == "" || slices.Contains(allowed, origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host != "" && u.Host == r.Host
+}
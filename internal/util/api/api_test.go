@@ -0,0 +1,56 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotModified(t *testing.T) {
+	etag, err := ETag(map[string]string{"id": "1"})
+	assert.NoError(t, err)
+	lastModified := time.Date(2024, 4, 13, 22, 0, 0, 0, time.UTC)
+
+	notModifiedTests := []struct {
+		headers     map[string]string
+		notModified bool
+	}{
+		{map[string]string{}, false},
+		{map[string]string{"If-None-Match": etag}, true},
+		{map[string]string{"If-None-Match": `"other", W/` + etag}, true},
+		{map[string]string{"If-None-Match": "*"}, true},
+		{map[string]string{"If-None-Match": `"other"`}, false},
+		{map[string]string{"If-Modified-Since": lastModified.Format(http.TimeFormat)}, true},
+		{map[string]string{"If-Modified-Since": lastModified.Add(-time.Minute).Format(http.TimeFormat)}, false},
+		// If-None-Match takes precedence
+		{map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": lastModified.Format(http.TimeFormat)}, false},
+	}
+
+	for _, tt := range notModifiedTests {
+		t.Run(fmt.Sprintf("headers: %v, not modified: %v", tt.headers, tt.notModified), func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+
+			got := NotModified(w, r, etag, lastModified)
+			assert.Equal(t, tt.notModified, got)
+			assert.Equal(t, etag, w.Header().Get("ETag"))
+			assert.Equal(t, lastModified.Format(http.TimeFormat), w.Header().Get("Last-Modified"))
+			if tt.notModified {
+				assert.Equal(t, http.StatusNotModified, w.Code)
+			}
+		})
+	}
+}
+
+func TestCacheControl(t *testing.T) {
+	w := httptest.NewRecorder()
+	CacheControl(w, 5*time.Minute)
+	assert.Equal(t, "public, max-age=300, must-revalidate", w.Header().Get("Cache-Control"))
+}
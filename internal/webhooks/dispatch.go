@@ -0,0 +1,122 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thebenkogan/ufc/internal/live"
+	"github.com/thebenkogan/ufc/internal/model"
+	"github.com/thebenkogan/ufc/internal/util/logs"
+)
+
+// Dispatcher notifies webhooks of lifecycle events
+type Dispatcher interface {
+	Dispatch(ctx context.Context, league, eventType string, data any) error
+}
+
+// Payload is the JSON body POSTed to webhooks
+type Payload struct {
+	// unique per dispatched event, so receivers can ignore redelivered payloads
+	Id        string    `json:"id"`
+	Type      string    `json:"type"`
+	League    string    `json:"league"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// Outbox queues a delivery for every webhook subscribed to a dispatched event.
+// The Worker sends them, so dispatching never waits on receivers.
+type Outbox struct {
+	webhooks WebhookRepository
+}
+
+func NewOutbox(webhooks WebhookRepository) *Outbox {
+	return &Outbox{
+		webhooks: webhooks,
+	}
+}
+
+func (o *Outbox) Dispatch(ctx context.Context, league, eventType string, data any) error {
+	hooks, err := o.webhooks.MatchingWebhooks(ctx, league, eventType)
+	if err != nil || len(hooks) == 0 {
+		return err
+	}
+
+	payload, err := json.Marshal(Payload{
+		Id:        uuid.New().String(),
+		Type:      eventType,
+		League:    league,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	deliveries := make([]*Delivery, 0, len(hooks))
+	for _, hook := range hooks {
+		deliveries = append(deliveries, &Delivery{WebhookId: hook.Id, EventType: eventType, Payload: payload})
+	}
+
+	logs.Logger(ctx).Info("queueing webhook deliveries", "type", eventType, "league", league, "webhooks", len(hooks))
+
+	return o.webhooks.EnqueueDeliveries(ctx, deliveries)
+}
+
+type PicksLockedData struct {
+	EventId   string `json:"event_id"`
+	Name      string `json:"name"`
+	StartTime string `json:"start_time"`
+}
+
+type FightResultData struct {
+	EventId string `json:"event_id"`
+	// the fights whose result changed
+	Fights []model.Fight `json:"fights"`
+}
+
+type EventFinishedData struct {
+	Event *model.Event `json:"event"`
+}
+
+type ScoresPostedData struct {
+	EventId string `json:"event_id"`
+	Scored  int    `json:"scored"`
+}
+
+// DispatchingBroker dispatches the lifecycle events found in published live updates.
+// Each update is published once by the instance that scraped it, so each event is dispatched once.
+type DispatchingBroker struct {
+	live.Broker
+	dispatcher Dispatcher
+}
+
+func NewDispatchingBroker(broker live.Broker, dispatcher Dispatcher) *DispatchingBroker {
+	return &DispatchingBroker{
+		Broker:     broker,
+		dispatcher: dispatcher,
+	}
+}
+
+func (b *DispatchingBroker) Publish(ctx context.Context, update *live.Update) error {
+	event := update.Event
+	dispatch := func(eventType string, data any) {
+		if err := b.dispatcher.Dispatch(ctx, event.League, eventType, data); err != nil {
+			logs.Logger(ctx).Warn("failed to dispatch webhook event", "type", eventType, "event ID", event.Id, "error", err)
+		}
+	}
+
+	if update.Started {
+		dispatch(EventPicksLocked, PicksLockedData{EventId: event.Id, Name: event.Name, StartTime: event.StartTime})
+	}
+	if len(update.Changed) > 0 {
+		dispatch(EventFightResult, FightResultData{EventId: event.Id, Fights: update.Changed})
+		if event.IsFinished() {
+			dispatch(EventEventFinished, EventFinishedData{Event: event})
+		}
+	}
+
+	return b.Broker.Publish(ctx, update)
+}
@@ -0,0 +1,136 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/thebenkogan/ufc/internal/model"
+	"github.com/thebenkogan/ufc/internal/util/api"
+	"github.com/thebenkogan/ufc/internal/util/logs"
+)
+
+type PostWebhookRequest struct {
+	URL        string   `json:"url"`
+	League     *string  `json:"league"`
+	EventTypes []string `json:"event_types"`
+}
+
+// PostWebhookResponse includes the signing secret, which is only ever returned on creation
+type PostWebhookResponse struct {
+	*Webhook
+	Secret string `json:"secret"`
+}
+
+func validateWebhook(req *PostWebhookRequest) error {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid url: %s", req.URL)
+	}
+	if req.League != nil && !slices.Contains(model.Leagues, *req.League) {
+		return fmt.Errorf("unknown league: %s", *req.League)
+	}
+	for _, eventType := range req.EventTypes {
+		if !slices.Contains(EventTypes, eventType) {
+			return fmt.Errorf("unknown event type: %s", eventType)
+		}
+	}
+	return nil
+}
+
+func newSecret() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func HandlePostWebhook(webhooks WebhookRepository) api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		var req PostWebhookRequest
+		api.Decode(r, &req)
+		if err := validateWebhook(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil
+		}
+
+		webhook := &Webhook{URL: req.URL, League: req.League, EventTypes: req.EventTypes, Secret: newSecret()}
+		if webhook.EventTypes == nil {
+			webhook.EventTypes = []string{}
+		}
+		if err := webhooks.CreateWebhook(ctx, webhook); err != nil {
+			return fmt.Errorf("error creating webhook: %w", err)
+		}
+		logs.Logger(ctx).Info("created webhook", "webhook ID", webhook.Id, "url", webhook.URL)
+
+		api.Encode(w, http.StatusCreated, PostWebhookResponse{Webhook: webhook, Secret: webhook.Secret})
+		return nil
+	}
+}
+
+func HandleListWebhooks(webhooks WebhookRepository) api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		hooks, err := webhooks.ListWebhooks(ctx)
+		if err != nil {
+			return fmt.Errorf("error listing webhooks: %w", err)
+		}
+
+		api.Encode(w, http.StatusOK, hooks)
+		return nil
+	}
+}
+
+// Returns the webhook ID from the request path, writing a 404 if it is not a number
+func webhookIdFromRequest(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "webhook not found", http.StatusNotFound)
+		return 0, false
+	}
+	return id, true
+}
+
+func HandleDeleteWebhook(webhooks WebhookRepository) api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		id, ok := webhookIdFromRequest(w, r)
+		if !ok {
+			return nil
+		}
+
+		deleted, err := webhooks.DeleteWebhook(ctx, id)
+		if err != nil {
+			return fmt.Errorf("error deleting webhook: %w", err)
+		}
+		if !deleted {
+			http.Error(w, "webhook not found", http.StatusNotFound)
+			return nil
+		}
+		logs.Logger(ctx).Info("deleted webhook", "webhook ID", id)
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+}
+
+const listDeliveriesLimit = 100
+
+func HandleListDeliveries(webhooks WebhookRepository) api.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		id, ok := webhookIdFromRequest(w, r)
+		if !ok {
+			return nil
+		}
+
+		deliveries, err := webhooks.ListDeliveries(ctx, id, listDeliveriesLimit)
+		if err != nil {
+			return fmt.Errorf("error listing deliveries: %w", err)
+		}
+
+		api.Encode(w, http.StatusOK, deliveries)
+		return nil
+	}
+}
@@ -0,0 +1,148 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Lifecycle events that webhooks can subscribe to
+const (
+	EventPicksLocked   = "picks.locked"
+	EventFightResult   = "fight.result"
+	EventEventFinished = "event.finished"
+	EventScoresPosted  = "scores.posted"
+)
+
+var EventTypes = []string{EventPicksLocked, EventFightResult, EventEventFinished, EventScoresPosted}
+
+type Webhook struct {
+	Id  int    `db:"id" json:"id"`
+	URL string `db:"url" json:"url"`
+	// nil to receive events of every league
+	League *string `db:"league" json:"league"`
+	// empty to receive every event type
+	EventTypes []string  `db:"event_types" json:"event_types"`
+	Secret     string    `db:"secret" json:"-"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Delivery is one payload queued for one webhook, with the outcome of its latest attempt
+type Delivery struct {
+	Id             int             `db:"id" json:"id"`
+	WebhookId      int             `db:"webhook_id" json:"webhook_id"`
+	EventType      string          `db:"event_type" json:"event_type"`
+	Payload        json.RawMessage `db:"payload" json:"payload"`
+	Status         string          `db:"status" json:"status"`
+	Attempts       int             `db:"attempts" json:"attempts"`
+	ResponseStatus *int            `db:"response_status" json:"response_status,omitempty"`
+	LastError      *string         `db:"last_error" json:"last_error,omitempty"`
+	NextAttemptAt  time.Time       `db:"next_attempt_at" json:"next_attempt_at"`
+	CreatedAt      time.Time       `db:"created_at" json:"created_at"`
+	DeliveredAt    *time.Time      `db:"delivered_at" json:"delivered_at,omitempty"`
+}
+
+type WebhookRepository interface {
+	CreateWebhook(ctx context.Context, webhook *Webhook) error
+	ListWebhooks(ctx context.Context) ([]*Webhook, error)
+	DeleteWebhook(ctx context.Context, id int) (bool, error)
+	// MatchingWebhooks returns the webhooks subscribed to the event type in the league
+	MatchingWebhooks(ctx context.Context, league, eventType string) ([]*Webhook, error)
+	EnqueueDeliveries(ctx context.Context, deliveries []*Delivery) error
+	// ClaimDueDeliveries returns up to limit pending deliveries that are due, hiding them from
+	// other callers for lease so each is only attempted by one instance at a time
+	ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*Delivery, error)
+	GetWebhooks(ctx context.Context, ids []int) ([]*Webhook, error)
+	// RecordAttempt stores the outcome of an attempt, a pending delivery is retried after retryIn
+	RecordAttempt(ctx context.Context, delivery *Delivery, retryIn time.Duration) error
+	// ListDeliveries returns the latest deliveries of the webhook, newest first
+	ListDeliveries(ctx context.Context, webhookId int, limit int) ([]*Delivery, error)
+}
+
+type PostgresWebhooks struct {
+	client *pgxpool.Pool
+}
+
+func NewPostgresWebhooks(client *pgxpool.Pool) *PostgresWebhooks {
+	return &PostgresWebhooks{
+		client: client,
+	}
+}
+
+func (p *PostgresWebhooks) CreateWebhook(ctx context.Context, webhook *Webhook) error {
+	return p.client.QueryRow(ctx, "INSERT INTO webhooks (url, league, event_types, secret) VALUES ($1, $2, $3, $4) RETURNING id, created_at", webhook.URL, webhook.League, webhook.EventTypes, webhook.Secret).Scan(&webhook.Id, &webhook.CreatedAt)
+}
+
+func (p *PostgresWebhooks) ListWebhooks(ctx context.Context) ([]*Webhook, error) {
+	rows, _ := p.client.Query(ctx, "SELECT * FROM webhooks ORDER BY id")
+	return pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[Webhook])
+}
+
+func (p *PostgresWebhooks) DeleteWebhook(ctx context.Context, id int) (bool, error) {
+	tag, err := p.client.Exec(ctx, "DELETE FROM webhooks WHERE id = $1", id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (p *PostgresWebhooks) MatchingWebhooks(ctx context.Context, league, eventType string) ([]*Webhook, error) {
+	rows, _ := p.client.Query(ctx, "SELECT * FROM webhooks WHERE (league IS NULL OR league = $1) AND (cardinality(event_types) = 0 OR $2 = ANY(event_types))", league, eventType)
+	return pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[Webhook])
+}
+
+func (p *PostgresWebhooks) EnqueueDeliveries(ctx context.Context, deliveries []*Delivery) error {
+	batch := &pgx.Batch{}
+	for _, d := range deliveries {
+		batch.Queue("INSERT INTO webhook_deliveries (webhook_id, event_type, payload) VALUES ($1, $2, $3)", d.WebhookId, d.EventType, d.Payload)
+	}
+	return p.client.SendBatch(ctx, batch).Close()
+}
+
+func (p *PostgresWebhooks) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*Delivery, error) {
+	rows, _ := p.client.Query(ctx, `
+		WITH due AS (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= CURRENT_TIMESTAMP
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d SET next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $2)
+		FROM due WHERE d.id = due.id
+		RETURNING d.*`, limit, lease.Seconds())
+	return pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[Delivery])
+}
+
+func (p *PostgresWebhooks) GetWebhooks(ctx context.Context, ids []int) ([]*Webhook, error) {
+	rows, _ := p.client.Query(ctx, "SELECT * FROM webhooks WHERE id = ANY($1)", ids)
+	return pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[Webhook])
+}
+
+func (p *PostgresWebhooks) RecordAttempt(ctx context.Context, delivery *Delivery, retryIn time.Duration) error {
+	_, err := p.client.Exec(ctx, `
+		UPDATE webhook_deliveries SET
+			status = $2,
+			attempts = $3,
+			response_status = $4,
+			last_error = $5,
+			next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $6),
+			delivered_at = CASE WHEN $2 = 'delivered' THEN CURRENT_TIMESTAMP END
+		WHERE id = $1`,
+		delivery.Id, delivery.Status, delivery.Attempts, delivery.ResponseStatus, delivery.LastError, retryIn.Seconds())
+	return err
+}
+
+func (p *PostgresWebhooks) ListDeliveries(ctx context.Context, webhookId int, limit int) ([]*Delivery, error) {
+	rows, _ := p.client.Query(ctx, "SELECT * FROM webhook_deliveries WHERE webhook_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2", webhookId, limit)
+	return pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[Delivery])
+}
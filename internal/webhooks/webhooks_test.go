@@ -0,0 +1,200 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thebenkogan/ufc/internal/live"
+	"github.com/thebenkogan/ufc/internal/model"
+)
+
+// testWebhooks is an in-memory WebhookRepository where every pending delivery is always due
+type testWebhooks struct {
+	mu         sync.Mutex
+	webhooks   []*Webhook
+	deliveries []*Delivery
+	retries    []time.Duration
+}
+
+func (t *testWebhooks) CreateWebhook(_ context.Context, webhook *Webhook) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	webhook.Id = len(t.webhooks) + 1
+	t.webhooks = append(t.webhooks, webhook)
+	return nil
+}
+
+func (t *testWebhooks) ListWebhooks(_ context.Context) ([]*Webhook, error) {
+	return t.webhooks, nil
+}
+
+func (t *testWebhooks) DeleteWebhook(_ context.Context, id int) (bool, error) {
+	panic("not implemented")
+}
+
+func (t *testWebhooks) MatchingWebhooks(_ context.Context, league, eventType string) ([]*Webhook, error) {
+	matching := make([]*Webhook, 0)
+	for _, hook := range t.webhooks {
+		if (hook.League == nil || *hook.League == league) && (len(hook.EventTypes) == 0 || slices.Contains(hook.EventTypes, eventType)) {
+			matching = append(matching, hook)
+		}
+	}
+	return matching, nil
+}
+
+func (t *testWebhooks) EnqueueDeliveries(_ context.Context, deliveries []*Delivery) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, d := range deliveries {
+		d.Id = len(t.deliveries) + 1
+		d.Status = DeliveryPending
+		t.deliveries = append(t.deliveries, d)
+	}
+	return nil
+}
+
+func (t *testWebhooks) ClaimDueDeliveries(_ context.Context, limit int, _ time.Duration) ([]*Delivery, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	due := make([]*Delivery, 0)
+	for _, d := range t.deliveries {
+		if d.Status == DeliveryPending && len(due) < limit {
+			due = append(due, d)
+		}
+	}
+	return due, nil
+}
+
+func (t *testWebhooks) GetWebhooks(_ context.Context, ids []int) ([]*Webhook, error) {
+	hooks := make([]*Webhook, 0)
+	for _, hook := range t.webhooks {
+		if slices.Contains(ids, hook.Id) {
+			hooks = append(hooks, hook)
+		}
+	}
+	return hooks, nil
+}
+
+func (t *testWebhooks) RecordAttempt(_ context.Context, _ *Delivery, retryIn time.Duration) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.retries = append(t.retries, retryIn)
+	return nil
+}
+
+func (t *testWebhooks) ListDeliveries(_ context.Context, webhookId int, limit int) ([]*Delivery, error) {
+	panic("not implemented")
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, backoff(1))
+	assert.Equal(t, time.Minute, backoff(2))
+	assert.Equal(t, 4*time.Minute, backoff(4))
+	assert.Equal(t, maxBackoff, backoff(20))
+}
+
+func TestClaimLease(t *testing.T) {
+	// a claimed batch must be sent before another instance can claim it again
+	worker := NewWorker(&testWebhooks{})
+	assert.Greater(t, claimLease, claimLimit*worker.client.Timeout)
+}
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"type":"fight.result"}`)
+	signature := Sign("secret", 1712345678, body)
+
+	assert.True(t, Verify("secret", 1712345678, body, signature))
+	assert.False(t, Verify("other", 1712345678, body, signature))
+	assert.False(t, Verify("secret", 1712345679, body, signature))
+	assert.False(t, Verify("secret", 1712345678, []byte(`{"type":"event.finished"}`), signature))
+}
+
+func TestDeliverWebhooks(t *testing.T) {
+	ctx := context.Background()
+
+	type received struct {
+		payload Payload
+		event   string
+	}
+	var mu sync.Mutex
+	var receipts []received
+	failures := 1
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, err := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+		assert.NoError(t, err)
+		if !Verify("secret", timestamp, body, r.Header.Get(SignatureHeader)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var payload Payload
+		assert.NoError(t, json.Unmarshal(body, &payload))
+		receipts = append(receipts, received{payload: payload, event: r.Header.Get(EventHeader)})
+	}))
+	defer ts.Close()
+
+	repo := &testWebhooks{}
+	pfl := "pfl"
+	require.NoError(t, repo.CreateWebhook(ctx, &Webhook{URL: ts.URL, Secret: "secret", EventTypes: []string{EventFightResult}}))
+	require.NoError(t, repo.CreateWebhook(ctx, &Webhook{URL: ts.URL, Secret: "secret", League: &pfl}))
+
+	outbox := NewOutbox(repo)
+	broker := NewDispatchingBroker(live.NewMemoryBroker(), outbox)
+	event := &model.Event{Id: "1", League: model.LeagueUFC, Fights: []model.Fight{{Fighters: []string{"A", "B"}, Winner: "A"}}}
+	require.NoError(t, broker.Publish(ctx, &live.Update{Event: event, Changed: event.Fights}))
+
+	// only the first webhook wants fight results, and it does not want event.finished
+	require.Len(t, repo.deliveries, 1)
+	assert.Equal(t, EventFightResult, repo.deliveries[0].EventType)
+
+	worker := NewWorker(repo)
+	require.NoError(t, worker.deliverDue(ctx))
+	assert.Equal(t, DeliveryPending, repo.deliveries[0].Status)
+	assert.Equal(t, http.StatusServiceUnavailable, *repo.deliveries[0].ResponseStatus)
+	assert.Equal(t, []time.Duration{baseBackoff}, repo.retries)
+
+	require.NoError(t, worker.deliverDue(ctx))
+	assert.Equal(t, DeliveryDelivered, repo.deliveries[0].Status)
+	assert.Equal(t, 2, repo.deliveries[0].Attempts)
+	assert.Nil(t, repo.deliveries[0].LastError)
+
+	require.Len(t, receipts, 1)
+	assert.Equal(t, EventFightResult, receipts[0].event)
+	assert.Equal(t, EventFightResult, receipts[0].payload.Type)
+	assert.Equal(t, model.LeagueUFC, receipts[0].payload.League)
+	assert.Equal(t, map[string]any{
+		"event_id": "1",
+		"fights":   []any{map[string]any{"fighters": []any{"A", "B"}, "winner": "A"}},
+	}, receipts[0].payload.Data)
+
+	t.Run("should fail a delivery after the last attempt", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer ts.Close()
+
+		d := &Delivery{Attempts: maxAttempts - 1, Payload: []byte("{}")}
+		retryIn := worker.attempt(ctx, &Webhook{URL: ts.URL}, d)
+		assert.Equal(t, DeliveryFailed, d.Status)
+		assert.Zero(t, retryIn)
+		assert.NotNil(t, d.LastError)
+	})
+}
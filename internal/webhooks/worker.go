@@ -0,0 +1,176 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/thebenkogan/ufc/internal/util/conv"
+	"github.com/thebenkogan/ufc/internal/util/logs"
+)
+
+const (
+	pollInterval = 10 * time.Second
+	// deliveries claimed per poll
+	claimLimit = 20
+	// a delivery that does not get a response within this time fails
+	deliveryTimeout = 10 * time.Second
+	// longer than sending every claimed delivery one after another can take,
+	// so a claimed delivery is never attempted by another instance at once
+	claimLease = claimLimit*deliveryTimeout + time.Minute
+	// a delivery is failed after this many attempts, about four hours after it was queued
+	maxAttempts = 10
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 6 * time.Hour
+)
+
+// Returns how long to wait after the given number of failed attempts, doubling each time
+func backoff(attempts int) time.Duration {
+	d := baseBackoff
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	TimestampHeader = "X-Webhook-Timestamp"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// Sign returns the signature of a payload sent at timestamp (unix seconds).
+// The timestamp is signed too, so receivers can reject replayed payloads.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the signature of a payload sent at timestamp
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Worker sends queued deliveries, retrying failures with exponential backoff
+type Worker struct {
+	webhooks WebhookRepository
+	client   *http.Client
+}
+
+func NewWorker(webhooks WebhookRepository) *Worker {
+	return &Worker{
+		webhooks: webhooks,
+		client:   &http.Client{Timeout: deliveryTimeout},
+	}
+}
+
+// Run sends due deliveries until ctx is done
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.deliverDue(ctx); err != nil {
+				logs.Logger(ctx).Warn("failed to deliver webhooks", "error", err)
+			}
+		}
+	}
+}
+
+func (w *Worker) deliverDue(ctx context.Context) error {
+	deliveries, err := w.webhooks.ClaimDueDeliveries(ctx, claimLimit, claimLease)
+	if err != nil || len(deliveries) == 0 {
+		return err
+	}
+
+	ids := make([]int, 0, len(deliveries))
+	for _, d := range deliveries {
+		ids = append(ids, d.WebhookId)
+	}
+	hooks, err := w.webhooks.GetWebhooks(ctx, ids)
+	if err != nil {
+		return err
+	}
+	hooksById := make(map[int]*Webhook, len(hooks))
+	for _, hook := range hooks {
+		hooksById[hook.Id] = hook
+	}
+
+	for _, d := range deliveries {
+		hook, ok := hooksById[d.WebhookId]
+		if !ok {
+			// deleted since the delivery was claimed, its deliveries are gone too
+			continue
+		}
+		retryIn := w.attempt(ctx, hook, d)
+		if err := w.webhooks.RecordAttempt(ctx, d, retryIn); err != nil {
+			logs.Logger(ctx).Warn("failed to record webhook attempt", "delivery ID", d.Id, "error", err)
+		}
+	}
+	return nil
+}
+
+// Sends the delivery and updates it with the outcome, returning when to retry it
+func (w *Worker) attempt(ctx context.Context, hook *Webhook, d *Delivery) time.Duration {
+	d.Attempts++
+	status, err := w.send(ctx, hook, d)
+	d.ResponseStatus = nil
+	if status != 0 {
+		d.ResponseStatus = conv.Ptr(status)
+	}
+
+	if err == nil {
+		d.Status = DeliveryDelivered
+		d.LastError = nil
+		return 0
+	}
+
+	d.LastError = conv.Ptr(err.Error())
+	if d.Attempts >= maxAttempts {
+		logs.Logger(ctx).Warn("webhook delivery failed permanently", "webhook ID", hook.Id, "delivery ID", d.Id, "error", err)
+		d.Status = DeliveryFailed
+		return 0
+	}
+	retryIn := backoff(d.Attempts)
+	logs.Logger(ctx).Info("webhook delivery failed, retrying", "webhook ID", hook.Id, "delivery ID", d.Id, "retry in", retryIn, "error", err)
+	return retryIn
+}
+
+// Posts the signed payload, returning the response status if there was one
+func (w *Worker) send(ctx context.Context, hook *Webhook, d *Delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, d.EventType)
+	req.Header.Set(DeliveryHeader, strconv.Itoa(d.Id))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, timestamp, d.Payload))
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16))
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, fmt.Errorf("unexpected status: %s", res.Status)
+	}
+	return res.StatusCode, nil
+}